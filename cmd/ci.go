@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/cache"
+	"github.com/LOTaher/grog/internal/lockfile"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
+	"github.com/spf13/cobra"
+)
+
+var ci = &cobra.Command{
+	Use:   "ci",
+	Short: "Clean install exactly what grog.lock describes.",
+	Long:  `Remove node_modules and install exactly the packages recorded in grog.lock. Fails if package.json and grog.lock disagree.`,
+	Run:   cleanInstall,
+}
+
+func init() {
+	addLinkFlags(ci)
+}
+
+func cleanInstall(cmd *cobra.Command, args []string) {
+	config, err := projectConfig(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if !lockfile.Exists(".") {
+		fmt.Printf("No %s found. Run grog install to create one.\n", lockfile.FileName)
+		os.Exit(1)
+	}
+
+	lockFile, err := lockfile.Read(".")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	pkg, err := manifest.Read(".")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	dependencies, err := pkg.AllDependencies()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if mismatches := compareRequires(dependencies, lockFile.Requires); len(mismatches) > 0 {
+		fmt.Printf("package.json and %s are out of sync:\n", lockfile.FileName)
+		for _, mismatch := range mismatches {
+			fmt.Printf("  %s\n", mismatch)
+		}
+		fmt.Println("Run grog install to update the lockfile.")
+		os.Exit(1)
+	}
+
+	graph, err := lockFile.Graph()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var unverified []string
+	for _, node := range graph.Sorted() {
+		if node.Integrity == "" {
+			unverified = append(unverified, resolver.Key(node.Name, node.Version))
+		}
+	}
+	if len(unverified) > 0 {
+		fmt.Printf("%s has no integrity hash for %s.\n", lockfile.FileName, strings.Join(unverified, ", "))
+		fmt.Println("Run grog install to update the lockfile.")
+		os.Exit(1)
+	}
+
+	for _, node := range graph.Sorted() {
+		if cached, err := cache.IsVersionCached(node.Name, node.Version); err != nil || !cached {
+			continue
+		}
+
+		if err := verifyCached(node.Name, node.Version); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if err := os.RemoveAll("node_modules"); err != nil {
+		fmt.Printf("failed to remove node_modules: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := installGraph(".", graph, config); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Installed %d packages from %s.\n", len(graph.Nodes), lockfile.FileName)
+}
+
+// verifyCached checks a cached package against its store index and removes
+// it if any file is corrupt, holding the entry lock so a concurrent install
+// cannot populate it at the same time.
+func verifyCached(name, version string) error {
+	lock, err := cache.Lock(name, version)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := cache.VerifyPackage(name, version); err != nil {
+		fmt.Printf("%v. Downloading it again.\n", err)
+		return cache.Remove(name, version)
+	}
+
+	return nil
+}
+
+func compareRequires(manifest, locked map[string]string) []string {
+	var mismatches []string
+
+	for name, spec := range manifest {
+		lockedSpec, ok := locked[name]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s@%s is missing from %s", name, spec, lockfile.FileName))
+		} else if lockedSpec != spec {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %s in package.json but %s in %s", name, spec, lockedSpec, lockfile.FileName))
+		}
+	}
+
+	for name, spec := range locked {
+		if _, ok := manifest[name]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s@%s is in %s but not in package.json", name, spec, lockfile.FileName))
+		}
+	}
+
+	sort.Strings(mismatches)
+	return mismatches
+}
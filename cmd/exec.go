@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/bin"
+	"github.com/LOTaher/grog/internal/cache"
+	"github.com/LOTaher/grog/internal/layout"
+	"github.com/LOTaher/grog/internal/lockfile"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
+	"github.com/LOTaher/grog/internal/script"
+	ver "github.com/LOTaher/grog/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var execDir = filepath.Join(os.Getenv("HOME"), ".grog", "exec")
+
+var execCmd = &cobra.Command{
+	Use:     "exec [package[@version]] [-- args...]",
+	Aliases: []string{"x"},
+	Short:   "Run a package's binary without adding it to the project.",
+	Long:    `Install a package into $HOME/.grog/exec and run its binary. Later runs reuse the installation. Example: grog exec prettier -- --check .`,
+	Args:    cobra.MinimumNArgs(1),
+	Run:     execPackage,
+}
+
+var execPackageSpec string
+
+// execConfig is used for every installation under execDir. Those are shared
+// between projects, so the project's "grog" settings don't apply to them.
+var execConfig = manifest.Config{
+	Layout:       layout.Isolated,
+	ImportMethod: cache.Hardlink,
+}
+
+func init() {
+	execCmd.Flags().StringVarP(&execPackageSpec, "package", "p", "", "Package to install. The first argument is then the name of the binary to run.")
+}
+
+func execPackage(cmd *cobra.Command, args []string) {
+	spec, binName, binArgs := args[0], "", args[1:]
+	if execPackageSpec != "" {
+		spec, binName = execPackageSpec, args[0]
+	}
+
+	installer := Installer{}
+	if err := installer.parsePackageDetails(spec); err != nil {
+		fmt.Printf("error parsing package details for %s: %v\n", spec, err)
+		os.Exit(1)
+	}
+
+	dir, err := ensureExecInstall(installer.Name, installer.Version, execConfig)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	binPath, err := execBinary(dir, installer.Name, binName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("unable to get the working directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	var env []string
+	for _, entry := range os.Environ() {
+		if name, _, _ := strings.Cut(entry, "="); !strings.EqualFold(name, "PATH") {
+			env = append(env, entry)
+		}
+	}
+	binDir := filepath.Join(dir, layout.ModulesDir, bin.Dir)
+	env = append(env, "PATH="+binDir+string(os.PathListSeparator)+script.Path(cwd))
+
+	command := exec.Command(binPath, binArgs...)
+	command.Env = env
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	if err := command.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			os.Exit(exitErr.ExitCode())
+		}
+
+		fmt.Printf("failed to run %s: %v\n", filepath.Base(binPath), err)
+		os.Exit(1)
+	}
+}
+
+func execPath(name, version string) string {
+	return filepath.Join(execDir, strings.ReplaceAll(name, "/", "+")+"@"+version)
+}
+
+// ensureExecInstall installs a package into its own directory under execDir,
+// reusing an earlier installation of the same resolved version.
+func ensureExecInstall(name, spec string, config manifest.Config) (string, error) {
+	if ok, _ := ver.ValidVersion(spec); ok && lockfile.Exists(execPath(name, spec)) {
+		return execPath(name, spec), nil
+	}
+
+	graph, err := resolver.Resolve(map[string]string{name: spec}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s@%s: %w", name, spec, err)
+	}
+
+	dir := execPath(name, graph.Edges[name].Version)
+	if lockfile.Exists(dir) {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(execDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("unable to create exec directory: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(execDir, ".tmp-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create exec directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := installGraph(staging, graph, config); err != nil {
+		return "", err
+	}
+
+	if err := lockfile.Write(staging, lockfile.FromGraph(graph)); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(staging, dir); err != nil && !lockfile.Exists(dir) {
+		return "", fmt.Errorf("failed to move %s into place: %w", dir, err)
+	}
+
+	return dir, nil
+}
+
+func execBinary(dir, name, binName string) (string, error) {
+	binDir := filepath.Join(dir, layout.ModulesDir, bin.Dir)
+
+	if binName == "" {
+		bins, err := bin.Read(filepath.Join(dir, layout.ModulesDir, name))
+		if err != nil {
+			return "", fmt.Errorf("failed to read bins of %s: %w", name, err)
+		}
+
+		var names []string
+		for binName := range bins {
+			names = append(names, binName)
+		}
+		sort.Strings(names)
+
+		switch {
+		case len(names) == 0:
+			return "", fmt.Errorf("%s does not provide any binaries", name)
+		case len(names) == 1:
+			binName = names[0]
+		case bins[path.Base(name)] != "":
+			binName = path.Base(name)
+		default:
+			return "", fmt.Errorf("%s provides several binaries (%s). Pick one with: grog exec -p %s <binary>", name, strings.Join(names, ", "), name)
+		}
+	}
+
+	binPath := filepath.Join(binDir, binName)
+	if _, err := os.Stat(binPath); err != nil {
+		return "", fmt.Errorf("binary '%s' not found in %s", binName, name)
+	}
+
+	return binPath, nil
+}
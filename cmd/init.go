@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/LOTaher/grog/internal/manifest"
 	"github.com/spf13/cobra"
 	"os"
     "path/filepath"
@@ -18,12 +19,8 @@ type PackageJSON struct {
 	Author          string            `json:"author"`
 	License         string            `json:"license"`
 	Private         bool              `json:"private"`
-	DevDependencies map[string]struct {
-		Version string `json:"version"`
-	} `json:"devDependencies"`
-	Dependencies map[string]struct {
-		Version string `json:"version"`
-	} `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Dependencies    map[string]string `json:"dependencies"`
 }
 
 var initCmd = &cobra.Command{
@@ -33,7 +30,7 @@ var initCmd = &cobra.Command{
 }
 
 func initProject(cmd *cobra.Command, args []string) {
-    packageJSONPath := "package.json"
+    packageJSONPath := manifest.FileName
 
     nameOfProject, err := os.Getwd()
     if err != nil {
@@ -54,12 +51,8 @@ func initProject(cmd *cobra.Command, args []string) {
 		Author:   "",
 		License:  "",
 		Private:  true,
-		DevDependencies: map[string]struct {
-			Version string `json:"version"`
-		}{},
-		Dependencies: map[string]struct {
-			Version string `json:"version"`
-		}{},
+		DevDependencies: map[string]string{},
+		Dependencies:    map[string]string{},
 	}
 
     packageJSONBytes, err := json.MarshalIndent(packageJSON, "", "  ")   
@@ -73,7 +66,7 @@ func initProject(cmd *cobra.Command, args []string) {
         return
     }
     
-    err = os.WriteFile(packageJSONPath, packageJSONBytes, 0644)
+    err = os.WriteFile(packageJSONPath, append(packageJSONBytes, '\n'), 0644)
     if err != nil {
         fmt.Println("Error creating package.json")
         return
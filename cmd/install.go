@@ -1,233 +1,476 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/LOTaher/grog/internal/cache"
-	"github.com/LOTaher/grog/internal/request"
-	"github.com/LOTaher/grog/internal/symlink"
-	"github.com/LOTaher/grog/internal/tarball"
+	"github.com/LOTaher/grog/internal/layout"
+	"github.com/LOTaher/grog/internal/lifecycle"
+	"github.com/LOTaher/grog/internal/lockfile"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
 	ver "github.com/LOTaher/grog/internal/version"
 	"github.com/spf13/cobra"
 )
 
 var install = &cobra.Command{
 	Use:   "install [package]",
-	Short: "Install a package.",
-	Long:  `Install a package. Example: grog install express`,
+	Short: "Install a package, or every dependency in package.json.",
+	Long:  `Install a package. Example: grog install express. Without arguments, installs the dependencies and devDependencies declared in package.json.`,
 	Run:   installPackage,
 }
 
+var (
+	dryRun            bool
+	production        bool
+	save              bool
+	noSave            bool
+	saveDev           bool
+	saveOptional      bool
+	savePeer          bool
+	saveExact         bool
+	savePrefix        string
+	layoutMode        string
+	importMethod      string
+	ignoreScripts     bool
+	scriptConcurrency int
+)
+
+func init() {
+	install.Flags().BoolVar(&dryRun, "dry-run", false, "Resolve the dependency graph and print the plan without installing.")
+	install.Flags().BoolVar(&production, "production", false, "Skip devDependencies when installing from package.json.")
+	install.Flags().BoolVarP(&save, "save", "S", true, "Save installed packages to package.json. This is the default.")
+	install.Flags().BoolVar(&noSave, "no-save", false, "Do not add the packages to package.json or grog.lock.")
+	install.Flags().BoolVarP(&saveDev, "save-dev", "D", false, "Save installed packages to devDependencies.")
+	install.Flags().BoolVarP(&saveOptional, "save-optional", "O", false, "Save installed packages to optionalDependencies.")
+	install.Flags().BoolVar(&savePeer, "save-peer", false, "Save installed packages to peerDependencies.")
+	install.Flags().BoolVarP(&saveExact, "save-exact", "E", false, "Save the exact resolved version instead of a range.")
+	install.Flags().StringVar(&savePrefix, "save-prefix", "^", "Prefix for saved versions: ^, ~ or an empty string for exact.")
+	addLinkFlags(install)
+}
+
+func addLinkFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&layoutMode, "layout", layout.Isolated, "node_modules layout: "+strings.Join(layout.Modes, ", ")+". Defaults to \"layout\" in the \"grog\" field of package.json.")
+	cmd.Flags().StringVar(&importMethod, "import-method", cache.Hardlink, "How files are imported from the cache: "+strings.Join(cache.ImportMethods, ", ")+". Defaults to \"importMethod\" in the \"grog\" field of package.json.")
+	cmd.Flags().BoolVar(&ignoreScripts, "ignore-scripts", false, "Do not run install scripts of dependencies.")
+	cmd.Flags().IntVar(&scriptConcurrency, "script-concurrency", runtime.NumCPU(), "Maximum number of install scripts to run at once.")
+}
+
 type Installer struct {
 	Name    string
 	Version string
 }
 
 func (i *Installer) parsePackageDetails(pkg string) error {
+	packageName, version, err := splitPackageSpec(pkg)
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		version = "latest"
+	}
+
+	i.Name = packageName
+	i.Version = version
+
+	return nil
+}
+
+// splitPackageSpec splits name@spec into its name and spec, keeping the
+// leading @ of scoped packages. The spec is empty if none was given.
+func splitPackageSpec(pkg string) (string, string, error) {
 	var packageName, version string
 	atCount := strings.Count(pkg, "@")
 
-	if atCount == 0 {
+	if atCount == 0 || atCount == 1 && strings.HasPrefix(pkg, "@") {
 		packageName = pkg
-		version = ""
 	} else if atCount == 1 {
 		parts := strings.SplitN(pkg, "@", 2)
 		packageName = parts[0]
 		version = parts[1]
-	} else if atCount == 2 {
+	} else if atCount == 2 && strings.HasPrefix(pkg, "@") {
 		parts := strings.SplitN(pkg, "@", 3)
 		packageName = parts[0] + "@" + parts[1]
 		version = parts[2]
 	} else {
-		return fmt.Errorf("invalid package format")
+		return "", "", fmt.Errorf("invalid package format")
 	}
 
-	if version == "" {
-		version = "latest"
-	} else {
-		ok, err := ver.ValidVersion(version)
-		if err != nil {
-			return fmt.Errorf("invalid version '%s': %w", version, err)
-		}
-		if !ok {
-			return fmt.Errorf("version '%s' is not a valid version", version)
-		}
+	if packageName == "" || packageName == "@" {
+		return "", "", fmt.Errorf("missing package name")
 	}
 
-	i.Name = packageName
-	i.Version = version
+	if version != "" && !ver.ValidSpec(version) {
+		return "", "", fmt.Errorf("'%s' is not a valid version, range or tag", version)
+	}
 
-	return nil
+	return packageName, version, nil
 }
 
 func installPackage(cmd *cobra.Command, args []string) {
-	if len(args) < 1 {
-		fmt.Println("Please specify a package name to install.")
-		return
+	if savePrefix != "^" && savePrefix != "~" && savePrefix != "" {
+		fmt.Printf("invalid save prefix '%s': expected ^, ~ or an empty string\n", savePrefix)
+		os.Exit(1)
 	}
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(args))
-
-	for _, arg := range args {
-		wg.Add(1)
-		go func(arg string) {
-			defer wg.Done()
-
-			installer := Installer{}
-			if err := installer.parsePackageDetails(arg); err != nil {
-				errChan <- fmt.Errorf("error parsing package details for %s: %w", arg, err)
-				return
-			}
-
-			fmt.Printf("Preparing to install package: %s@%s\n", installer.Name, installer.Version)
+	config, err := projectConfig(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-			if err := performInstallation(installer.Name, installer.Version); err != nil {
-				errChan <- fmt.Errorf("installation failed for %s@%s: %w", installer.Name, installer.Version, err)
-			}
-		}(arg)
+	locked, err := loadLockedGraph()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	wg.Wait()
-	close(errChan)
+	var dependencies map[string]string
+	var requested, devOnly []string
+	previous := locked
 
-	for err := range errChan {
+	if len(args) < 1 {
+		pkg, err := manifest.Read(".")
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Println("No package.json found. Please specify a package name to install.")
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		dependencies, err = pkg.AllDependencies()
+		if err == nil && production {
+			devOnly, err = pkg.DevOnly()
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else {
+		dependencies, requested, locked, err = requestedDependencies(args, locked)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
 	}
-}
 
-func performInstallation(name, version string) error {
-	homeDir, err := os.UserHomeDir()
+	graph, err := resolver.Resolve(dependencies, locked)
 	if err != nil {
-		return fmt.Errorf("unable to get user home directory: %w", err)
+		fmt.Printf("failed to resolve dependencies: %v\n", err)
+		os.Exit(1)
+	}
+
+	reportConflicts(graph)
+
+	target := graph
+	if len(devOnly) > 0 {
+		target = graph.Without(devOnly...)
 	}
 
-	cacheDir := filepath.Join(homeDir, ".grog", "cache")
+	if dryRun {
+		printPlan(target)
+		return
+	}
 
-	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
-		return fmt.Errorf("unable to create cache directory: %w", err)
+	if err := installGraph(".", target, config); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	if len(version) == 1 {
-		v := ver.GetMostRecentVersion(name)
-		version = v
+	if len(requested) > 0 && !noSave {
+		saved := make(map[string]string)
+		for _, name := range requested {
+			saved[name] = saveSpec(graph.Dependencies[name], graph.Edges[name].Version)
+			graph.Dependencies[name] = saved[name]
+		}
+
+		if err := saveManifestDependencies(saveField(), saved); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	}
 
-	if strings.ContainsAny(version, "<>~^=") {
-		foundVersion, err := ver.BestMatchingVersion(name, version)
+	if len(requested) > 0 && noSave {
+		// The requested packages stay out of package.json, so grog.lock is
+		// resolved from what it declares and grog ci keeps agreeing with it.
+		declared, err := declaredDependencies(previous)
 		if err != nil {
-			return fmt.Errorf("failed to resolve version constraint '%s' : %w", version, err)
+			fmt.Println(err)
+			os.Exit(1)
 		}
 
-		version = foundVersion
+		if graph, err = resolver.Resolve(declared, previous); err != nil {
+			fmt.Printf("failed to resolve dependencies: %v\n", err)
+			os.Exit(1)
+		}
 	}
-	if version == "latest" {
-		if cached, err := cache.IsPackageCached(name); cached {
-			if err != nil {
-				return err
-			}
 
-			versions, err := ver.GetVersions(name)
-			if err != nil {
-				return err
-			}
+	if err := lockfile.Write(".", lockfile.FromGraph(graph)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
 
-			for _, version := range versions {
-				isLatest, err := cache.IsLockFileLatest(name, version)
-				if err != nil {
-					return err
-				}
-				if isLatest {
-					if err := symlink.SymlinkPackage(name, version); err != nil {
-						return err
-					}
-
-					lockfile, err := cache.ReadLockFile(name, version)
-					if err != nil {
-						return err
-					}
-
-					fmt.Printf("Package %s@%s is latest version and already exists in the cache. Skipping installation.\n", name, version)
-
-					for depName, depVersion := range lockfile.Dependencies {
-						fmt.Printf("Installing dependency %s@%s\n", depName, depVersion)
-						if err := symlink.SymlinkPackage(depName, depVersion); err != nil {
-							return err
-						}
-					}
-					return nil
-				}
-			}
+// declaredDependencies returns the dependencies in package.json, or those
+// recorded in grog.lock when there is no package.json.
+func declaredDependencies(locked *resolver.Graph) (map[string]string, error) {
+	dependencies := make(map[string]string)
+
+	pkg, err := manifest.Read(".")
+	if err == nil {
+		return pkg.AllDependencies()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	} else if locked != nil {
+		for name, spec := range locked.Dependencies {
+			dependencies[name] = spec
 		}
 	}
 
-	if exists, err := cache.IsVersionCached(name, version); exists {
-		if err != nil {
-			return err
+	return dependencies, nil
+}
+
+func requestedDependencies(args []string, locked *resolver.Graph) (map[string]string, []string, *resolver.Graph, error) {
+	dependencies, err := declaredDependencies(locked)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var requested []string
+	for _, arg := range args {
+		installer := Installer{}
+		if err := installer.parsePackageDetails(arg); err != nil {
+			return nil, nil, nil, fmt.Errorf("error parsing package details for %s: %w", arg, err)
 		}
 
-		fmt.Printf("Package %s@%s already exists in the cache. Skipping installation.\n", name, version)
+		fmt.Printf("Preparing to install package: %s@%s\n", installer.Name, installer.Version)
+		dependencies[installer.Name] = installer.Version
+		requested = append(requested, installer.Name)
+	}
 
-		if err := symlink.SymlinkPackage(name, version); err != nil {
+	if locked != nil {
+		locked = locked.Without(requested...)
+	}
+
+	return dependencies, requested, locked, nil
+}
+
+func saveManifestDependencies(field string, specs map[string]string) error {
+	pkg := manifest.New()
+	if manifest.Exists(".") {
+		var err error
+		if pkg, err = manifest.Read("."); err != nil {
 			return err
 		}
+	}
 
-		lockfile, err := cache.ReadLockFile(name, version)
-		if err != nil {
-			return err
+	for name, spec := range specs {
+		fields := []string{field}
+		if field == "" {
+			// Without a flag the package keeps its place, which may be more
+			// than one field, such as peerDependencies and devDependencies.
+			fields = nil
+			for _, other := range manifest.DependencyFields {
+				dependencies, err := pkg.Dependencies(other)
+				if err != nil {
+					return err
+				}
+				if _, ok := dependencies[name]; ok {
+					fields = append(fields, other)
+				}
+			}
+			if len(fields) == 0 {
+				fields = []string{"dependencies"}
+			}
+		} else {
+			for _, other := range manifest.DependencyFields {
+				if other == field {
+					continue
+				}
+				if _, err := pkg.RemoveDependency(other, name); err != nil {
+					return err
+				}
+			}
 		}
 
-		for depName, depVersion := range lockfile.Dependencies {
-			fmt.Printf("Installing dependency %s@%s\n", depName, depVersion)
-			if err := symlink.SymlinkPackage(depName, depVersion); err != nil {
+		for _, field := range fields {
+			if err := pkg.SetDependency(field, name, spec); err != nil {
 				return err
 			}
 		}
+	}
 
-		return nil
+	return pkg.Write(".")
+}
 
-	} else {
+// saveField returns the field requested with a flag, or an empty string to
+// keep packages where package.json already has them.
+func saveField() string {
+	switch {
+	case saveDev:
+		return "devDependencies"
+	case saveOptional:
+		return "optionalDependencies"
+	case savePeer:
+		return "peerDependencies"
+	default:
+		return ""
+	}
+}
 
-		packageInfo, err := request.FetchResponse(name, version)
+func saveSpec(spec, version string) string {
+	if saveExact {
+		return version
+	}
+
+	if ok, _ := ver.ValidVersion(spec); ok || !ver.ValidRange(spec) {
+		return savePrefix + version
+	}
+
+	return spec
+}
+
+func projectConfig(cmd *cobra.Command) (manifest.Config, error) {
+	var config manifest.Config
+	if manifest.Exists(".") {
+		pkg, err := manifest.Read(".")
 		if err != nil {
-			return err
+			return config, err
 		}
 
-		isLatest, err := ver.IsLatestVersion(packageInfo.Name, packageInfo.Version)
-		if err != nil {
-			return err
+		if config, err = pkg.Config(); err != nil {
+			return config, err
 		}
+	}
 
-		targetDir := filepath.Join(cacheDir, packageInfo.Name, packageInfo.Version)
+	if config.Layout == "" || cmd.Flags().Changed("layout") {
+		config.Layout = layoutMode
+	}
+	if config.ImportMethod == "" || cmd.Flags().Changed("import-method") {
+		config.ImportMethod = importMethod
+	}
 
-		if err := tarball.DownloadTarball(packageInfo.Dist.Tarball, targetDir); err != nil {
-			return fmt.Errorf("failed to download tarball: %w", err)
+	if !layout.Valid(config.Layout) {
+		return config, fmt.Errorf("invalid layout '%s': expected one of %s", config.Layout, strings.Join(layout.Modes, ", "))
+	}
+
+	if !cache.ValidImportMethod(config.ImportMethod) {
+		return config, fmt.Errorf("invalid import method '%s': expected one of %s", config.ImportMethod, strings.Join(cache.ImportMethods, ", "))
+	}
+
+	return config, nil
+}
+
+func loadLockedGraph() (*resolver.Graph, error) {
+	if !lockfile.Exists(".") {
+		return nil, nil
+	}
+
+	lockFile, err := lockfile.Read(".")
+	if err != nil {
+		return nil, err
+	}
+
+	return lockFile.Graph()
+}
+
+func reportConflicts(graph *resolver.Graph) {
+	for _, conflict := range graph.Conflicts() {
+		fmt.Printf("%s resolved to multiple versions, each nested under the packages that need it:\n", conflict.Name)
+		for _, version := range conflict.Versions {
+			node := graph.Nodes[resolver.Key(conflict.Name, version)]
+
+			var requiredBy []string
+			if graph.Edges[node.Name] == node {
+				requiredBy = append(requiredBy, "project")
+			}
+			for _, dependent := range graph.Dependents(node) {
+				requiredBy = append(requiredBy, resolver.Key(dependent.Name, dependent.Version))
+			}
+
+			fmt.Printf("  %s (required by %s)\n", version, strings.Join(requiredBy, ", "))
 		}
+	}
+}
+
+func printPlan(graph *resolver.Graph) {
+	fmt.Printf("Resolved %d packages:\n", len(graph.Nodes))
+	for _, node := range graph.Sorted() {
+		fmt.Printf("  %s\n", resolver.Key(node.Name, node.Version))
+	}
+}
+
+func installGraph(dir string, graph *resolver.Graph, config manifest.Config) error {
+	nodes := graph.Sorted()
 
-		err = cache.CreateLockFile(packageInfo.Name, packageInfo.Version, isLatest, packageInfo.Dependencies)
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(nodes))
+
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *resolver.Node) {
+			defer wg.Done()
+
+			if err := fetchPackage(node); err != nil {
+				errChan <- fmt.Errorf("installation failed for %s@%s: %w", node.Name, node.Version, err)
+			}
+		}(node)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
 		if err != nil {
 			return err
 		}
+	}
 
-		if err := symlink.SymlinkPackage(packageInfo.Name, packageInfo.Version); err != nil {
-			return err
-		}
+	if err := linkGraph(dir, graph, config); err != nil {
+		return err
+	}
 
-		fmt.Printf("Successfully installed %s@%s\n", packageInfo.Name, packageInfo.Version)
+	for name, node := range graph.Edges {
+		fmt.Printf("Successfully installed %s@%s\n", name, node.Version)
+	}
 
-		for depName, depVersion := range packageInfo.Dependencies {
-			fmt.Printf("Installing dependency %s@%s\n", depName, depVersion)
-			if err := performInstallation(depName, depVersion); err != nil {
-				return fmt.Errorf("failed to install dependency %s@%s: %w", depName, depVersion, err)
-			}
-		}
+	return nil
+}
+
+func linkGraph(dir string, graph *resolver.Graph, config manifest.Config) error {
+	if err := layout.Install(dir, config.Layout, config.ImportMethod, graph); err != nil {
+		return fmt.Errorf("failed to link node_modules: %w", err)
+	}
+
+	if ignoreScripts {
+		return nil
+	}
+
+	policy := lifecycle.Policy{Allow: config.AllowScripts, Deny: config.DenyScripts}
+	if err := lifecycle.Run(dir, graph, layout.Locations(dir, config.Layout, graph), policy, scriptConcurrency); err != nil {
+		return fmt.Errorf("failed to run install scripts: %w", err)
+	}
+
+	return nil
+}
+
+func fetchPackage(node *resolver.Node) error {
+	fetched, err := cache.Ensure(node.Name, node.Version, node.Tarball, node.Integrity, node.Latest, node.Dependencies)
+	if err != nil {
+		return err
+	}
+
+	if !fetched {
+		fmt.Printf("Package %s@%s already exists in the cache. Skipping download.\n", node.Name, node.Version)
 	}
 
 	return nil
@@ -0,0 +1,383 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/layout"
+	"github.com/LOTaher/grog/internal/lockfile"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
+	ver "github.com/LOTaher/grog/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var ls = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "Show the installed dependency tree.",
+	Long:    `Print the dependency tree installed in node_modules, or the one recorded in grog.lock if nothing is installed. Marks missing, extraneous, deduped and invalid packages. Example: grog ls --depth=1`,
+	Run:     listPackages,
+}
+
+var (
+	lsDepth int
+	lsJSON  bool
+	lsProd  bool
+	lsDev   bool
+)
+
+func init() {
+	ls.Flags().IntVar(&lsDepth, "depth", -1, "Maximum depth of the tree to print, where 0 shows only direct dependencies. Negative values show everything.")
+	ls.Flags().BoolVar(&lsJSON, "json", false, "Print the tree as JSON.")
+	ls.Flags().BoolVar(&lsProd, "prod", false, "Only show dependencies that are not devDependencies.")
+	ls.Flags().BoolVar(&lsDev, "dev", false, "Only show devDependencies.")
+}
+
+type TreeNode struct {
+	Version      string               `json:"version,omitempty"`
+	Required     string               `json:"required,omitempty"`
+	Missing      bool                 `json:"missing,omitempty"`
+	Invalid      bool                 `json:"invalid,omitempty"`
+	Extraneous   bool                 `json:"extraneous,omitempty"`
+	Deduped      bool                 `json:"deduped,omitempty"`
+	Dependencies map[string]*TreeNode `json:"dependencies,omitempty"`
+}
+
+type treeRoot struct {
+	Name         string               `json:"name,omitempty"`
+	Version      string               `json:"version,omitempty"`
+	Dependencies map[string]*TreeNode `json:"dependencies,omitempty"`
+}
+
+// treePackage is an installed or locked package. Its key identifies it for
+// deduplication: the real path on disk, or name@version in the lockfile.
+type treePackage struct {
+	key          string
+	version      string
+	dependencies map[string]string
+	optional     map[string]bool
+}
+
+type treeSource func(parent, name string) (treePackage, bool)
+
+func listPackages(cmd *cobra.Command, args []string) {
+	if lsProd && lsDev {
+		fmt.Println("--prod and --dev cannot be used together.")
+		os.Exit(1)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("unable to get the working directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	locked, err := loadLockedGraph()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	root := treeRoot{Name: filepath.Base(dir)}
+	var dependencies map[string]string
+	var devOnly []string
+
+	if manifest.Exists(".") {
+		pkg, err := manifest.Read(".")
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if name := pkg.String("name"); name != "" {
+			root.Name = name
+		}
+		root.Version = pkg.String("version")
+
+		if dependencies, err = pkg.AllDependencies(); err == nil {
+			devOnly, err = pkg.DevOnly()
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else if locked != nil {
+		dependencies = locked.Dependencies
+	} else {
+		fmt.Printf("No %s or %s found.\n", manifest.FileName, lockfile.FileName)
+		os.Exit(1)
+	}
+
+	dev := make(map[string]bool)
+	for _, name := range devOnly {
+		dev[name] = true
+	}
+
+	shown := make(map[string]string)
+	for name, spec := range dependencies {
+		if lsProd && dev[name] || lsDev && !dev[name] {
+			continue
+		}
+		shown[name] = spec
+	}
+
+	var source treeSource
+	var parent string
+	if _, err := os.Stat(layout.ModulesDir); err == nil || locked == nil {
+		source, parent = diskSource, dir
+	} else {
+		source = lockSource(locked)
+	}
+
+	root.Dependencies = buildTree(source, parent, shown, nil, make(map[string]bool), lsDepth)
+
+	if !lsProd && !lsDev {
+		reachable := make(map[string]bool)
+		buildTree(source, parent, dependencies, nil, reachable, -1)
+
+		for name, node := range extraneous(source, parent, dependencies, locked, reachable) {
+			root.Dependencies[name] = node
+		}
+	}
+
+	if lsJSON {
+		data, err := json.MarshalIndent(root, "", "  ")
+		if err != nil {
+			fmt.Printf("failed to marshal tree: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	label := root.Name
+	if root.Version != "" {
+		label += "@" + root.Version
+	}
+	fmt.Printf("%s %s\n", label, dir)
+
+	if len(root.Dependencies) == 0 {
+		fmt.Println("└── (empty)")
+		return
+	}
+
+	printTree("", root.Dependencies)
+}
+
+// buildTree resolves dependencies from parent, descending at most depth
+// levels, or without limit if depth is negative. Packages already in seen
+// are marked as deduped instead of being expanded again.
+func buildTree(source treeSource, parent string, dependencies map[string]string, optional, seen map[string]bool, depth int) map[string]*TreeNode {
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	children := make(map[string]*TreeNode)
+	for _, name := range names {
+		spec := dependencies[name]
+
+		pkg, ok := source(parent, name)
+		if !ok {
+			if !optional[name] {
+				children[name] = &TreeNode{Required: spec, Missing: true}
+			}
+			continue
+		}
+
+		node := &TreeNode{Version: pkg.version}
+		if ver.ValidRange(spec) {
+			if ok, err := ver.Satisfies(pkg.version, spec); err != nil || !ok {
+				node.Invalid = true
+				node.Required = spec
+			}
+		}
+		children[name] = node
+
+		if seen[pkg.key] {
+			node.Deduped = true
+			continue
+		}
+
+		if depth == 0 {
+			continue
+		}
+
+		seen[pkg.key] = true
+		node.Dependencies = buildTree(source, pkg.key, pkg.dependencies, pkg.optional, seen, depth-1)
+	}
+
+	return children
+}
+
+// diskSource finds an installed package the way Node does, looking in the
+// node_modules of the requiring package and then of each parent directory.
+func diskSource(parent, name string) (treePackage, bool) {
+	for dir := parent; ; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) != layout.ModulesDir {
+			if real, err := filepath.EvalSymlinks(filepath.Join(dir, layout.ModulesDir, name)); err == nil {
+				if pkg, ok := readTreePackage(real); ok {
+					return pkg, true
+				}
+			}
+		}
+
+		if filepath.Dir(dir) == dir {
+			return treePackage{}, false
+		}
+	}
+}
+
+func readTreePackage(dir string) (treePackage, bool) {
+	pkg, err := manifest.Read(dir)
+	if err != nil {
+		return treePackage{}, false
+	}
+
+	dependencies, err := pkg.Dependencies("dependencies")
+	if err != nil {
+		return treePackage{}, false
+	}
+
+	optional := make(map[string]bool)
+	if optionalDependencies, err := pkg.Dependencies("optionalDependencies"); err == nil {
+		for name, spec := range optionalDependencies {
+			dependencies[name] = spec
+			optional[name] = true
+		}
+	}
+
+	return treePackage{key: dir, version: pkg.String("version"), dependencies: dependencies, optional: optional}, true
+}
+
+func lockSource(graph *resolver.Graph) treeSource {
+	return func(parent, name string) (treePackage, bool) {
+		var node *resolver.Node
+		if parent == "" {
+			node = graph.Edges[name]
+		} else if parentNode, ok := graph.Nodes[parent]; ok {
+			node = parentNode.Edges[name]
+		}
+
+		if node == nil {
+			return treePackage{}, false
+		}
+
+		dependencies := make(map[string]string)
+		for name, spec := range node.Dependencies {
+			dependencies[name] = spec
+		}
+
+		optional := make(map[string]bool)
+		for name, spec := range node.Optional {
+			dependencies[name] = spec
+			optional[name] = true
+		}
+
+		return treePackage{key: resolver.Key(node.Name, node.Version), version: node.Version, dependencies: dependencies, optional: optional}, true
+	}
+}
+
+// extraneous returns packages that are installed at the top level of
+// node_modules, or locked as direct dependencies, without anything
+// depending on them.
+func extraneous(source treeSource, parent string, dependencies map[string]string, locked *resolver.Graph, reachable map[string]bool) map[string]*TreeNode {
+	nodes := make(map[string]*TreeNode)
+
+	if parent == "" {
+		for name, node := range locked.Edges {
+			if _, ok := dependencies[name]; !ok {
+				nodes[name] = &TreeNode{Version: node.Version, Extraneous: true}
+			}
+		}
+		return nodes
+	}
+
+	for _, name := range topLevelPackages(filepath.Join(parent, layout.ModulesDir)) {
+		if _, ok := dependencies[name]; ok {
+			continue
+		}
+
+		pkg, ok := source(parent, name)
+		if ok && !reachable[pkg.key] {
+			nodes[name] = &TreeNode{Version: pkg.version, Extraneous: true}
+		}
+	}
+
+	return nodes
+}
+
+func topLevelPackages(modulesDir string) []string {
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if strings.HasPrefix(name, "@") {
+			scoped, err := os.ReadDir(filepath.Join(modulesDir, name))
+			if err != nil {
+				continue
+			}
+			for _, scopedEntry := range scoped {
+				names = append(names, name+"/"+scopedEntry.Name())
+			}
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func printTree(prefix string, dependencies map[string]*TreeNode) {
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		node := dependencies[name]
+
+		branch, indent := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		fmt.Printf("%s%s%s\n", prefix, branch, treeLabel(name, node))
+		printTree(prefix+indent, node.Dependencies)
+	}
+}
+
+func treeLabel(name string, node *TreeNode) string {
+	if node.Missing {
+		return name + "@" + node.Required + " " + paint("MISSING", colorRed)
+	}
+
+	label := name + "@" + node.Version
+	if node.Invalid {
+		label += " " + paint("invalid: "+node.Required, colorRed)
+	}
+	if node.Extraneous {
+		label += " " + paint("extraneous", colorRed)
+	}
+	if node.Deduped {
+		label += " " + paint("deduped", colorGray)
+	}
+
+	return label
+}
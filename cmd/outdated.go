@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/LOTaher/grog/internal/layout"
+	"github.com/LOTaher/grog/internal/manifest"
+	ver "github.com/LOTaher/grog/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var outdated = &cobra.Command{
+	Use:   "outdated [package...]",
+	Short: "List dependencies with newer versions available.",
+	Long:  `Compare each direct dependency's installed version with the highest version its range allows (wanted) and the registry's latest version. Example: grog outdated --json`,
+	Run:   listOutdated,
+}
+
+var (
+	outdatedJSON     bool
+	outdatedExitCode bool
+)
+
+func init() {
+	outdated.Flags().BoolVar(&outdatedJSON, "json", false, "Print the report as JSON.")
+	outdated.Flags().BoolVar(&outdatedExitCode, "exit-code", false, "Exit with status 1 if any dependency is outdated.")
+}
+
+type Outdated struct {
+	Current string `json:"current,omitempty"`
+	Wanted  string `json:"wanted"`
+	Latest  string `json:"latest"`
+	Type    string `json:"type"`
+}
+
+func listOutdated(cmd *cobra.Command, args []string) {
+	pkg, err := manifest.Read(".")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	dependencies, err := pkg.AllDependencies()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 {
+		selected := make(map[string]string)
+		for _, name := range args {
+			spec, ok := dependencies[name]
+			if !ok {
+				fmt.Printf("%s is not a dependency in %s.\n", name, manifest.FileName)
+				os.Exit(1)
+			}
+			selected[name] = spec
+		}
+		dependencies = selected
+	}
+
+	locked, err := loadLockedGraph()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(dependencies))
+	report := make(map[string]Outdated)
+
+	for name, spec := range dependencies {
+		wg.Add(1)
+		go func(name, spec string) {
+			defer wg.Done()
+
+			versions, err := ver.FetchVersions(name)
+			if err != nil {
+				errChan <- fmt.Errorf("failed to fetch versions of %s: %w", name, err)
+				return
+			}
+
+			// Resolve like install and update do, so wanted is what they
+			// would pick for this range.
+			wanted, err := versions.Resolve(spec)
+			if err != nil {
+				errChan <- fmt.Errorf("failed to find a version of %s matching %s: %w", name, spec, err)
+				return
+			}
+
+			current := installedVersion(name)
+			if current == "" && locked != nil && locked.Edges[name] != nil {
+				current = locked.Edges[name].Version
+			}
+
+			latest := versions.Latest()
+			if current == wanted && current == latest {
+				return
+			}
+
+			mu.Lock()
+			report[name] = Outdated{Current: current, Wanted: wanted, Latest: latest, Type: pkg.DependencyType(name)}
+			mu.Unlock()
+		}(name, spec)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if outdatedJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Printf("failed to marshal report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	} else if len(report) > 0 {
+		printOutdated(report)
+	}
+
+	if outdatedExitCode && len(report) > 0 {
+		os.Exit(1)
+	}
+}
+
+func installedVersion(name string) string {
+	pkg, err := manifest.Read(filepath.Join(layout.ModulesDir, name))
+	if err != nil {
+		return ""
+	}
+
+	return pkg.String("version")
+}
+
+func printOutdated(report map[string]Outdated) {
+	names := make([]string, 0, len(report))
+	for name := range report {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rows [][]string
+	for _, name := range names {
+		entry := report[name]
+
+		current := entry.Current
+		if current == "" {
+			current = "MISSING"
+		}
+
+		nameColor := colorYellow
+		if current != entry.Wanted {
+			nameColor = colorRed
+		}
+
+		rows = append(rows, []string{
+			paint(name, nameColor),
+			current,
+			paint(entry.Wanted, colorGreen),
+			paint(entry.Latest, colorMagenta),
+			entry.Type,
+		})
+	}
+
+	printTable([]string{"Package", "Current", "Wanted", "Latest", "Type"}, rows)
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -12,6 +14,10 @@ var root = &cobra.Command{
 }
 
 func Execute() {
+	if strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") == "grogx" {
+		root.SetArgs(append([]string{"exec"}, os.Args[1:]...))
+	}
+
 	err := root.Execute()
 	if err != nil {
 		os.Exit(1)
@@ -20,7 +26,15 @@ func Execute() {
 
 func init() {
 	root.AddCommand(install)
+	root.AddCommand(ci)
 	root.AddCommand(clear)
 	root.AddCommand(uninstall)
+	root.AddCommand(update)
+	root.AddCommand(outdated)
+	root.AddCommand(run)
+	root.AddCommand(start)
+	root.AddCommand(test)
+	root.AddCommand(execCmd)
+	root.AddCommand(ls)
     root.AddCommand(initCmd)
 }
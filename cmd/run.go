@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/script"
+	"github.com/spf13/cobra"
+)
+
+var run = &cobra.Command{
+	Use:     "run [script] [-- args...]",
+	Aliases: []string{"run-script"},
+	Short:   "Run a script from package.json.",
+	Long:    `Run a script from package.json with node_modules/.bin on PATH, along with its pre and post scripts. Without arguments, lists the available scripts. Arguments after the script name are passed to it. Example: grog run build --watch`,
+	// Flags belong to the script, not to grog.
+	DisableFlagParsing: true,
+	Run:                runScript,
+}
+
+var start = &cobra.Command{
+	Use:                "start [-- args...]",
+	Short:              "Run the start script from package.json.",
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if wantsHelp(cmd, args) {
+			return
+		}
+
+		runLifecycle("start", scriptArgs(args))
+	},
+}
+
+var test = &cobra.Command{
+	Use:                "test [-- args...]",
+	Aliases:            []string{"t"},
+	Short:              "Run the test script from package.json.",
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if wantsHelp(cmd, args) {
+			return
+		}
+
+		runLifecycle("test", scriptArgs(args))
+	},
+}
+
+func runScript(cmd *cobra.Command, args []string) {
+	if wantsHelp(cmd, args) {
+		return
+	}
+
+	if len(args) < 1 {
+		listScripts()
+		return
+	}
+
+	runLifecycle(args[0], scriptArgs(args[1:]))
+}
+
+// wantsHelp prints the command's help if it was the only thing asked for.
+// Flag parsing is disabled for script commands, so cobra won't do it.
+func wantsHelp(cmd *cobra.Command, args []string) bool {
+	if len(args) != 1 || (args[0] != "-h" && args[0] != "--help") {
+		return false
+	}
+
+	cmd.Help()
+	return true
+}
+
+// scriptArgs returns the arguments to pass to a script. A leading "--" is
+// dropped, so "grog run build -- --watch" and "grog run build --watch" agree.
+func scriptArgs(args []string) []string {
+	if len(args) > 0 && args[0] == "--" {
+		return args[1:]
+	}
+
+	return args
+}
+
+func listScripts() {
+	pkg, err := manifest.Read(".")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	scripts, err := pkg.Scripts()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Scripts available in %s:\n", pkg.String("name"))
+	for _, name := range names {
+		fmt.Printf("  %s\n    %s\n", name, scripts[name])
+	}
+}
+
+func runLifecycle(name string, args []string) {
+	pkg, err := manifest.Read(".")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	scripts, err := pkg.Scripts()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if _, ok := scripts[name]; !ok {
+		fmt.Printf("Missing script: \"%s\"\n", name)
+		os.Exit(1)
+	}
+
+	for _, event := range []string{"pre" + name, name, "post" + name} {
+		var eventArgs []string
+		if event == name {
+			eventArgs = args
+		}
+
+		command, err := script.Command(".", pkg, event, eventArgs)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if command == nil {
+			continue
+		}
+
+		fmt.Printf("\n> %s@%s %s\n> %s\n\n", pkg.String("name"), pkg.String("version"), event, command.Args[len(command.Args)-1])
+
+		command.Stdin = os.Stdin
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+
+		if err := command.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+				fmt.Printf("Script \"%s\" exited with code %d\n", event, exitErr.ExitCode())
+				os.Exit(exitErr.ExitCode())
+			}
+
+			fmt.Printf("failed to run script \"%s\": %v\n", event, err)
+			os.Exit(1)
+		}
+	}
+}
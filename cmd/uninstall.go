@@ -3,10 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"strings"
 	"sync"
 
+	"github.com/LOTaher/grog/internal/bin"
 	"github.com/LOTaher/grog/internal/cache"
+	"github.com/LOTaher/grog/internal/lockfile"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
 	ver "github.com/LOTaher/grog/internal/version"
 	"github.com/spf13/cobra"
 )
@@ -24,38 +27,9 @@ type Uninstaller struct {
 }
 
 func (u *Uninstaller) parsePackageDetails(pkg string) error {
-	var packageName, version string
-	atCount := strings.Count(pkg, "@")
-
-	if atCount == 0 {
-		packageName = pkg
-		version = ""
-	} else if atCount == 1 {
-		parts := strings.SplitN(pkg, "@", 2)
-		packageName = parts[0]
-		version = parts[1]
-	} else if atCount == 2 {
-		parts := strings.SplitN(pkg, "@", 3)
-		packageName = parts[0] + "@" + parts[1]
-		version = parts[2]
-	} else {
-		return fmt.Errorf("invalid package format")
-	}
-
-	if version == "" {
-		latestVersion, err := ver.GetLatestVersion(pkg)
-		if err != nil {
-			return fmt.Errorf("unable to get latest version: %w", err)
-		}
-		version = latestVersion
-	} else {
-		ok, err := ver.ValidVersion(version)
-		if err != nil {
-			return fmt.Errorf("invalid version '%s': %w", version, err)
-		}
-		if !ok {
-			return fmt.Errorf("version '%s' is not a valid version", version)
-		}
+	packageName, version, err := splitPackageSpec(pkg)
+	if err != nil {
+		return err
 	}
 
 	u.Name = packageName
@@ -64,14 +38,25 @@ func (u *Uninstaller) parsePackageDetails(pkg string) error {
 	return nil
 }
 
+func init() {
+	addLinkFlags(uninstall)
+}
+
 func uninstallPackage(cmd *cobra.Command, args []string) {
 	if len(args) < 1 {
 		fmt.Println("Please specify a package name to uninstall.")
 		return
 	}
 
+	config, err := projectConfig(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(args))
+	removed := make(chan string, len(args))
 
 	for _, arg := range args {
 		wg.Add(1)
@@ -85,14 +70,41 @@ func uninstallPackage(cmd *cobra.Command, args []string) {
 			}
 
 			if err := performUninstallation(uninstaller.Name, uninstaller.Version); err != nil {
-				errChan <- fmt.Errorf("uninstallation failed for %s@%s: %w", uninstaller.Name, uninstaller.Version, err)
+				errChan <- fmt.Errorf("uninstallation failed for %s: %w", arg, err)
+				return
 			}
 
+			removed <- uninstaller.Name
+
 		}(arg)
 	}
 
 	wg.Wait()
 	close(errChan)
+	close(removed)
+
+	var names []string
+	for name := range removed {
+		names = append(names, name)
+	}
+
+	if err := removeManifestDependencies(names); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	remaining, err := removeFromLockFile(names)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if remaining != nil {
+		if err := linkGraph(".", remaining, config); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
 
 	for err := range errChan {
 		if err != nil {
@@ -102,15 +114,54 @@ func uninstallPackage(cmd *cobra.Command, args []string) {
 	}
 }
 
+func removeManifestDependencies(names []string) error {
+	if !manifest.Exists(".") {
+		return nil
+	}
+
+	pkg, err := manifest.Read(".")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		for _, field := range manifest.DependencyFields {
+			if _, err := pkg.RemoveDependency(field, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return pkg.Write(".")
+}
+
+func removeFromLockFile(names []string) (*resolver.Graph, error) {
+	locked, err := loadLockedGraph()
+	if err != nil || locked == nil {
+		return nil, err
+	}
+
+	remaining := locked.Without(names...)
+	return remaining, lockfile.Write(".", lockfile.FromGraph(remaining))
+}
+
 func performUninstallation(name, version string) error {
 
     if _, err := os.Stat("./node_modules"); os.IsNotExist(err) {
         fmt.Println("No packages installed within this directory.")
     } else {
-        if err := cache.RemovePackageDependenciesLocally(name, version); err != nil {
-            return fmt.Errorf("failed to remove dependencies: %w", err)
+        if installed := installedVersion(name); installed != "" {
+            version = installed
+        }
+        if ok, _ := ver.ValidVersion(version); ok && !lockfile.Exists(".") {
+            if err := cache.RemovePackageDependenciesLocally(name, version); err != nil {
+                return fmt.Errorf("failed to remove dependencies: %w", err)
+            }
         }
         os.RemoveAll("./node_modules/" + name)
+        if err := bin.Prune("node_modules"); err != nil {
+            return fmt.Errorf("failed to remove executables: %w", err)
+        }
         fmt.Printf("Uninstalled package: %s\n", name)
     }
 
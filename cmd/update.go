@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/lockfile"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
+	ver "github.com/LOTaher/grog/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var update = &cobra.Command{
+	Use:   "update [package...]",
+	Short: "Update dependencies to the newest versions their ranges allow.",
+	Long:  `Re-resolve dependencies to the newest versions allowed by the ranges in package.json and update grog.lock and node_modules. With --latest, update to the latest versions even across major versions and rewrite the ranges in package.json. Example: grog update express`,
+	Run:   updatePackages,
+}
+
+var updateLatest bool
+
+func init() {
+	update.Flags().BoolVar(&updateLatest, "latest", false, "Update to the latest version, even if package.json's range does not allow it.")
+	addLinkFlags(update)
+}
+
+func updatePackages(cmd *cobra.Command, args []string) {
+	config, err := projectConfig(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	pkg, err := manifest.Read(".")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	dependencies, err := pkg.AllDependencies()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	names := args
+	if len(names) == 0 {
+		for name := range dependencies {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := dependencies[name]; !ok {
+			fmt.Printf("%s is not a dependency in %s.\n", name, manifest.FileName)
+			os.Exit(1)
+		}
+	}
+
+	locked, err := loadLockedGraph()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	before := make(map[string]string)
+	var unlocked *resolver.Graph
+	if locked != nil {
+		for name, node := range locked.Edges {
+			before[name] = node.Version
+		}
+		if len(args) > 0 {
+			unlocked = locked.Without(names...)
+		}
+	}
+
+	rewritten := make(map[string]string)
+	if updateLatest {
+		if rewritten, err = latestSpecs(names, dependencies); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for name, spec := range rewritten {
+			dependencies[name] = spec
+		}
+	}
+
+	graph, err := resolver.Resolve(dependencies, unlocked)
+	if err != nil {
+		fmt.Printf("failed to resolve dependencies: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := installGraph(".", graph, config); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for name, spec := range rewritten {
+		if err := pkg.SetDependency(pkg.DependencyType(name), name, spec); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if len(rewritten) > 0 {
+		if err := pkg.Write("."); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if err := lockfile.Write(".", lockfile.FromGraph(graph)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var rows [][]string
+	for _, name := range names {
+		after := graph.Edges[name].Version
+		if before[name] == after {
+			continue
+		}
+
+		previous := before[name]
+		if previous == "" {
+			previous = "-"
+		}
+		rows = append(rows, []string{name, previous, after, dependencies[name]})
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("All packages are up to date.")
+		return
+	}
+
+	fmt.Println()
+	printTable([]string{"Package", "Before", "After", "Range"}, rows)
+}
+
+// latestSpecs returns new ranges for the packages whose range does not
+// allow the latest version. Exact versions stay exact and tilde ranges stay
+// tilde ranges; any other range becomes a caret range on the latest version.
+func latestSpecs(names []string, dependencies map[string]string) (map[string]string, error) {
+	specs := make(map[string]string)
+	for _, name := range names {
+		spec := dependencies[name]
+		if !ver.ValidRange(spec) {
+			continue
+		}
+
+		versions, err := ver.FetchVersions(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch versions of %s: %w", name, err)
+		}
+
+		latest := versions.Latest()
+		if ok, err := ver.Satisfies(latest, spec); err == nil && ok {
+			continue
+		}
+
+		if exact, _ := ver.ValidVersion(spec); exact {
+			specs[name] = latest
+		} else if strings.HasPrefix(spec, "~") {
+			specs[name] = "~" + latest
+		} else {
+			specs[name] = "^" + latest
+		}
+	}
+
+	return specs, nil
+}
@@ -0,0 +1,177 @@
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/symlink"
+)
+
+const Dir = ".bin"
+
+func Read(packageDir string) (map[string]string, error) {
+	pkg, err := manifest.Read(packageDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bins, err := pkg.Bins()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bins) == 0 && pkg.BinDirectory() != "" {
+		entries, err := os.ReadDir(filepath.Join(packageDir, filepath.FromSlash(pkg.BinDirectory())))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				bins[entry.Name()] = pkg.BinDirectory() + "/" + entry.Name()
+			}
+		}
+	}
+
+	valid := make(map[string]string)
+	for name, target := range bins {
+		name = filepath.Base(filepath.FromSlash(name))
+		target = filepath.Clean(filepath.FromSlash(target))
+		if name == "." || name == ".." || filepath.IsAbs(target) || target == ".." || strings.HasPrefix(target, ".."+string(filepath.Separator)) {
+			fmt.Printf("Skipping invalid bin '%s' in %s\n", name, packageDir)
+			continue
+		}
+
+		valid[name] = target
+	}
+
+	return valid, nil
+}
+
+// Link recreates the .bin directory of modulesDir with a symlink for every
+// executable declared by the given packages, keyed by package name.
+func Link(modulesDir string, packages map[string]string) error {
+	binDir := filepath.Join(modulesDir, Dir)
+	if err := os.RemoveAll(binDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", binDir, err)
+	}
+
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		packageDir := packages[name]
+		bins, err := Read(packageDir)
+		if err != nil {
+			return fmt.Errorf("failed to read bins of %s: %w", name, err)
+		}
+
+		for binName, target := range bins {
+			targetPath := filepath.Join(packageDir, target)
+			if _, err := os.Stat(targetPath); err != nil {
+				fmt.Printf("Skipping bin '%s' of %s: %v\n", binName, name, err)
+				continue
+			}
+
+			if err := makeExecutable(targetPath); err != nil {
+				return fmt.Errorf("failed to make %s executable: %w", targetPath, err)
+			}
+
+			if err := os.MkdirAll(binDir, os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create %s: %w", binDir, err)
+			}
+
+			relative, err := filepath.Rel(binDir, targetPath)
+			if err != nil {
+				return err
+			}
+
+			if err := symlink.Link(relative, filepath.Join(binDir, binName)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// makeExecutable copies the file before changing its mode, since it may be
+// hardlinked to the shared store.
+func makeExecutable(path string) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0111 {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0111); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func Prune(modulesDir string) error {
+	binDir := filepath.Join(modulesDir, Dir)
+	entries, err := os.ReadDir(binDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(binDir, entry.Name())
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// Concurrent uninstalls prune the same directory, so the link
+			// may already be gone.
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -6,25 +6,54 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/LOTaher/grog/internal/tarball"
 	ver "github.com/LOTaher/grog/internal/version"
 )
 
 var Cache = filepath.Join(os.Getenv("HOME"), ".grog", "cache")
 
+// Internal holds the store, locks and staging directories. Package names
+// cannot start with a dot, so it never collides with a cached package.
+var Internal = filepath.Join(Cache, ".grog")
+
+var Staging = filepath.Join(Internal, "tmp")
+
+// staleAfter is how old a staging directory must be before it is treated as
+// left behind by a process that was killed.
+const staleAfter = 24 * time.Hour
+
 type LockFile struct {
 	IsLatest     bool              `json:"isLatest"`
 	Dependencies map[string]string `json:"dependencies"`
 }
 
+const CompleteMarker = ".grog-complete"
+
+// IsVersionCached reports whether a complete entry for the package exists. It
+// does not touch incomplete entries; Ensure repairs them under the entry lock.
 func IsVersionCached(name, version string) (bool, error) {
-	homeDir, err := os.UserHomeDir()
+	cacheDir := filepath.Join(Cache, name, version)
+	if _, err := os.Stat(filepath.Join(cacheDir, CompleteMarker)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	integrity, err := entryIntegrity(name, version)
 	if err != nil {
-		return false, fmt.Errorf("unable to get user home directory: %w", err)
+		return false, err
 	}
 
-	cacheDir := filepath.Join(homeDir, ".grog", "cache", name, version)
-	if _, err := os.Stat(cacheDir); err != nil {
+	path, err := indexPath(integrity)
+	if err != nil {
+		return false, nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
 			return false, nil
 		}
@@ -35,6 +64,112 @@ func IsVersionCached(name, version string) (bool, error) {
 	return true, nil
 }
 
+// removeIncomplete removes a cache entry that exists but is not complete. The
+// caller must hold the entry lock.
+func removeIncomplete(name, version string) error {
+	if _, err := os.Stat(filepath.Join(Cache, name, version)); os.IsNotExist(err) {
+		return nil
+	}
+
+	fmt.Printf("Cache entry %s@%s is incomplete. Removing it.\n", name, version)
+	if err := Remove(name, version); err != nil {
+		return fmt.Errorf("failed to remove incomplete cache entry: %w", err)
+	}
+
+	return nil
+}
+
+func stagingPath(name, version string) string {
+	return filepath.Join(Staging, strings.ReplaceAll(name, "/", "+")+"@"+version)
+}
+
+// sweepStaging removes staging directories that no process has touched for
+// a long time.
+func sweepStaging() {
+	entries, err := os.ReadDir(Staging)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && time.Since(info.ModTime()) > staleAfter {
+			os.RemoveAll(filepath.Join(Staging, entry.Name()))
+		}
+	}
+}
+
+// Populate downloads a package into the cache. The caller must hold the entry
+// lock, which also makes the package's staging directory its own.
+func Populate(name, version, url, integrity string, isLatest bool, dependencies map[string]string) error {
+	stagingDir := stagingPath(name, version)
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return fmt.Errorf("unable to remove stale staging directory: %w", err)
+	}
+	if err := os.MkdirAll(stagingDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	if err := tarball.DownloadTarball(url, stagingDir, integrity); err != nil {
+		return fmt.Errorf("failed to download tarball: %w", err)
+	}
+
+	packageDir, err := packageRoot(stagingDir)
+	if err != nil {
+		return err
+	}
+
+	if err := storePackage(name, version, integrity, packageDir); err != nil {
+		return err
+	}
+
+	if err := writeLockFile(packageDir, isLatest, dependencies); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(stagingDir, CompleteMarker), []byte(integrity+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write completion marker: %w", err)
+	}
+
+	targetDir := filepath.Join(Cache, name, version)
+	if err := os.MkdirAll(filepath.Dir(targetDir), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create cache directory: %w", err)
+	}
+
+	if err := removeIncomplete(name, version); err != nil {
+		return err
+	}
+
+	if err := os.Rename(stagingDir, targetDir); err != nil {
+		return fmt.Errorf("failed to move %s@%s into the cache: %w", name, version, err)
+	}
+
+	return nil
+}
+
+func packageRoot(stagingDir string) (string, error) {
+	packageDir := filepath.Join(stagingDir, "package")
+	if _, err := os.Stat(packageDir); err == nil {
+		return packageDir, nil
+	}
+
+	entries, err := os.ReadDir(stagingDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return "", fmt.Errorf("tarball has no package directory")
+	}
+
+	if err := os.Rename(filepath.Join(stagingDir, entries[0].Name()), packageDir); err != nil {
+		return "", fmt.Errorf("failed to normalize package directory: %w", err)
+	}
+
+	return packageDir, nil
+}
+
 func IsPackageCached(name string) (bool, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -60,6 +195,10 @@ func CreateLockFile(name, version string, isLatest bool, dependencies map[string
 	}
 
 	versionDir := filepath.Join(homeDir, ".grog", "cache", name, version, "package")
+	return writeLockFile(versionDir, isLatest, dependencies)
+}
+
+func writeLockFile(versionDir string, isLatest bool, dependencies map[string]string) error {
 	if _, err := os.Stat(versionDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(versionDir, 0755); err != nil {
 			return fmt.Errorf("unable to create directory %s: %w", versionDir, err)
@@ -130,13 +269,8 @@ func IsLockFileLatest(name, version string) (bool, error) {
 
 func RemovePackageDependenciesGlobally(name, version string) error {
 
-	if len(version) == 1 {
-		v := ver.GetMostRecentVersion(name)
-		version = v
-	}
-
-	if strings.ContainsAny(version, "<>~^=") {
-		foundVersion, err := ver.BestMatchingVersion(name, version)
+	if ok, _ := ver.ValidVersion(version); !ok {
+		foundVersion, err := ver.FindCorrectVersion(name, version)
 		if err != nil {
 			return fmt.Errorf("failed to resolve version constraint '%s' : %w", version, err)
 		}
@@ -150,6 +284,9 @@ func RemovePackageDependenciesGlobally(name, version string) error {
 
 	if lockfile.Dependencies != nil {
 		for dep, ver := range lockfile.Dependencies {
+			if strings.HasPrefix(dep, ".") {
+				continue
+			}
 			os.RemoveAll("./node_modules/" + dep)
 			os.RemoveAll(Cache + "/" + dep)
 			if err := RemovePackageDependenciesGlobally(dep, ver); err != nil {
@@ -163,13 +300,8 @@ func RemovePackageDependenciesGlobally(name, version string) error {
 
 func RemovePackageDependenciesLocally(name, version string) error {
 
-	if len(version) == 1 {
-		v := ver.GetMostRecentVersion(name)
-		version = v
-	}
-
-	if strings.ContainsAny(version, "<>~^=") {
-		foundVersion, err := ver.BestMatchingVersion(name, version)
+	if ok, _ := ver.ValidVersion(version); !ok {
+		foundVersion, err := ver.FindCorrectVersion(name, version)
 		if err != nil {
 			return fmt.Errorf("failed to resolve version constraint '%s' : %w", version, err)
 		}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package cache
+
+import (
+	"errors"
+	"os"
+)
+
+func cloneFile(src, dest string, mode os.FileMode) error {
+	return errors.ErrUnsupported
+}
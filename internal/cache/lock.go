@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type EntryLock struct {
+	file *os.File
+}
+
+type flight struct {
+	wg      sync.WaitGroup
+	fetched bool
+	err     error
+}
+
+var (
+	flightsMu sync.Mutex
+	flights   = make(map[string]*flight)
+	sweepOnce sync.Once
+)
+
+func Lock(name, version string) (*EntryLock, error) {
+	locksDir := filepath.Join(Internal, "locks")
+	if err := os.MkdirAll(locksDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create locks directory: %w", err)
+	}
+
+	lockPath := filepath.Join(locksDir, strings.ReplaceAll(name, "/", "+")+"@"+version+".lock")
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open lock file %s: %w", lockPath, err)
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to lock %s@%s: %w", name, version, err)
+	}
+
+	return &EntryLock{file: file}, nil
+}
+
+func (l *EntryLock) Unlock() error {
+	defer l.file.Close()
+	return unlockFile(l.file)
+}
+
+func Ensure(name, version, url, integrity string, isLatest bool, dependencies map[string]string) (bool, error) {
+	key := name + "@" + version
+
+	flightsMu.Lock()
+	if f, ok := flights[key]; ok {
+		flightsMu.Unlock()
+		f.wg.Wait()
+		return false, f.err
+	}
+
+	f := &flight{}
+	f.wg.Add(1)
+	flights[key] = f
+	flightsMu.Unlock()
+
+	f.fetched, f.err = ensure(name, version, url, integrity, isLatest, dependencies)
+	f.wg.Done()
+
+	flightsMu.Lock()
+	delete(flights, key)
+	flightsMu.Unlock()
+
+	return f.fetched, f.err
+}
+
+func ensure(name, version, url, integrity string, isLatest bool, dependencies map[string]string) (bool, error) {
+	if strings.HasPrefix(name, ".") {
+		return false, fmt.Errorf("invalid package name '%s'", name)
+	}
+
+	if cached, err := IsVersionCached(name, version); err != nil || cached {
+		return false, err
+	}
+
+	sweepOnce.Do(sweepStaging)
+
+	lock, err := Lock(name, version)
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	if cached, err := IsVersionCached(name, version); err != nil || cached {
+		return false, err
+	}
+
+	if err := Populate(name, version, url, integrity, isLatest, dependencies); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
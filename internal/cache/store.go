@@ -0,0 +1,414 @@
+package cache
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var Store = filepath.Join(Internal, "store")
+
+const (
+	Hardlink = "hardlink"
+	Clone    = "clone"
+	Copy     = "copy"
+)
+
+var ImportMethods = []string{Hardlink, Clone, Copy}
+
+func ValidImportMethod(method string) bool {
+	for _, m := range ImportMethods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+type IndexEntry struct {
+	Hash string      `json:"hash,omitempty"`
+	Mode fs.FileMode `json:"mode,omitempty"`
+	Size int64       `json:"size,omitempty"`
+	Link string      `json:"link,omitempty"`
+}
+
+type Index struct {
+	Name      string                `json:"name"`
+	Version   string                `json:"version"`
+	Integrity string                `json:"integrity"`
+	Files     map[string]IndexEntry `json:"files"`
+}
+
+func indexPath(integrity string) (string, error) {
+	entries := strings.Fields(integrity)
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no integrity information")
+	}
+
+	algorithm, digest, ok := strings.Cut(entries[0], "-")
+	if !ok {
+		return "", fmt.Errorf("invalid integrity '%s'", integrity)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid integrity '%s': %w", integrity, err)
+	}
+
+	key := hex.EncodeToString(raw)
+	return filepath.Join(Store, "index", algorithm, key[:2], key[2:]+".json"), nil
+}
+
+func filePath(hash string) string {
+	return filepath.Join(Store, "files", hash[:2], hash[2:])
+}
+
+func entryIntegrity(name, version string) (string, error) {
+	marker, err := os.ReadFile(filepath.Join(Cache, name, version, CompleteMarker))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(marker)), nil
+}
+
+func ReadIndex(name, version string) (Index, error) {
+	integrity, err := entryIntegrity(name, version)
+	if err != nil {
+		return Index{}, fmt.Errorf("%s@%s is not cached: %w", name, version, err)
+	}
+
+	path, err := indexPath(integrity)
+	if err != nil {
+		return Index{}, err
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return Index{}, fmt.Errorf("failed to read store index for %s@%s: %w", name, version, err)
+	}
+
+	var index Index
+	if err := json.Unmarshal(file, &index); err != nil {
+		return Index{}, fmt.Errorf("failed to unmarshal store index for %s@%s: %w", name, version, err)
+	}
+
+	return index, nil
+}
+
+func storePackage(name, version, integrity, packageDir string) error {
+	index := Index{
+		Name:      name,
+		Version:   version,
+		Integrity: integrity,
+		Files:     make(map[string]IndexEntry),
+	}
+
+	err := filepath.WalkDir(packageDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(packageDir, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			index.Files[filepath.ToSlash(rel)] = IndexEntry{Link: target}
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		hash, err := importFile(path, info.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the store: %w", rel, err)
+		}
+
+		index.Files[filepath.ToSlash(rel)] = IndexEntry{Hash: hash, Mode: info.Mode().Perm(), Size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	path, err := indexPath(integrity)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal store index: %w", err)
+	}
+
+	return writeAtomic(path, data, 0644)
+}
+
+func importFile(path string, mode fs.FileMode) (string, error) {
+	hash, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	if mode&0111 != 0 {
+		hash += "-exec"
+	}
+
+	storePath := filePath(hash)
+	if err := os.MkdirAll(filepath.Dir(storePath), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	// The downloaded file replaces any stored copy. It was just checked
+	// against the tarball's integrity, so this also repairs a store file that
+	// VerifyPackage found corrupt for every package sharing it.
+	tmp, err := os.CreateTemp(filepath.Dir(storePath), ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+
+	if err := os.Link(path, tmp.Name()); err != nil {
+		return hash, copyFile(path, storePath, mode)
+	}
+
+	if err := os.Rename(tmp.Name(), storePath); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return hash, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha512.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func Materialize(name, version, dest, method string) error {
+	index, err := ReadIndex(name, version)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+
+	for rel, entry := range index.Files {
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", target, err)
+		}
+
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to replace %s: %w", target, err)
+		}
+
+		if entry.Link != "" {
+			if err := os.Symlink(entry.Link, target); err != nil {
+				return fmt.Errorf("failed to link %s: %w", target, err)
+			}
+			continue
+		}
+
+		storePath := filePath(entry.Hash)
+		info, err := os.Stat(storePath)
+		if err != nil {
+			return fmt.Errorf("store is missing %s for %s@%s: %w", rel, name, version, err)
+		}
+		if info.Size() != entry.Size {
+			return fmt.Errorf("store file for %s in %s@%s is corrupt", rel, name, version)
+		}
+
+		if err := importStoreFile(storePath, target, entry.Mode, method); err != nil {
+			return fmt.Errorf("failed to import %s: %w", rel, err)
+		}
+	}
+
+	return nil
+}
+
+// VerifyPackage checks the store files of a cached package against their
+// hashes. Corrupt files are left in place since other packages may share
+// them; downloading the package again replaces them.
+func VerifyPackage(name, version string) error {
+	index, err := ReadIndex(name, version)
+	if err != nil {
+		return err
+	}
+
+	var corrupt []string
+	for rel, entry := range index.Files {
+		if entry.Link != "" {
+			continue
+		}
+
+		storePath := filePath(entry.Hash)
+		hash, err := hashFile(storePath)
+		if err == nil && strings.TrimSuffix(entry.Hash, "-exec") == hash {
+			continue
+		}
+
+		corrupt = append(corrupt, rel)
+	}
+
+	if len(corrupt) > 0 {
+		return fmt.Errorf("%s@%s has corrupt files in the store: %s", name, version, strings.Join(corrupt, ", "))
+	}
+
+	return nil
+}
+
+// Detach replaces the files of an imported package with private clones or
+// copies, so that install scripts editing them in place cannot change the
+// store shared by every project. Nested node_modules belong to other
+// packages and are left alone.
+func Detach(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != dir && d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		tmp := filepath.Join(filepath.Dir(path), ".tmp-"+d.Name())
+		if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := importStoreFile(path, tmp, info.Mode().Perm(), Clone); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", path, err)
+		}
+
+		return os.Rename(tmp, path)
+	})
+}
+
+func Remove(name, version string) error {
+	return os.RemoveAll(filepath.Join(Cache, name, version))
+}
+
+// importStoreFile copies a file out of the store using the requested method,
+// falling back to the next cheapest one when it isn't supported, for example
+// when the store and the project are on different filesystems.
+func importStoreFile(src, dest string, mode fs.FileMode, method string) error {
+	switch method {
+	case Hardlink:
+		if err := os.Link(src, dest); err == nil {
+			return nil
+		}
+		fallthrough
+	case Clone:
+		if err := cloneFile(src, dest, mode); err == nil {
+			return nil
+		}
+	}
+
+	return copyFile(src, dest, mode)
+}
+
+func copyFile(src, dest string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dest)
+}
+
+func writeAtomic(path string, data []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
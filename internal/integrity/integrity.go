@@ -0,0 +1,18 @@
+package integrity
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// FromShasum converts the hex sha1 shasum that older registry entries carry
+// in place of an integrity field into a subresource integrity string.
+func FromShasum(shasum string) (string, error) {
+	digest, err := hex.DecodeString(shasum)
+	if err != nil {
+		return "", fmt.Errorf("invalid shasum '%s': %w", shasum, err)
+	}
+
+	return "sha1-" + base64.StdEncoding.EncodeToString(digest), nil
+}
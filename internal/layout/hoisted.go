@@ -0,0 +1,41 @@
+package layout
+
+import (
+	"github.com/LOTaher/grog/internal/resolver"
+	ver "github.com/LOTaher/grog/internal/version"
+)
+
+// HoistedTree places one version of every package at the top level, the one
+// the project depends on directly or otherwise the one most packages depend
+// on, and nests the remaining versions under the packages that need them.
+func HoistedTree(graph *resolver.Graph) *Placement {
+	root := newPlacement(nil, nil)
+
+	hoisted := make(map[string]*resolver.Node)
+	dependents := make(map[*resolver.Node]int)
+	for _, node := range graph.Sorted() {
+		dependents[node] = len(graph.Dependents(node))
+
+		if direct, ok := graph.Edges[node.Name]; ok {
+			hoisted[node.Name] = direct
+			continue
+		}
+
+		current, ok := hoisted[node.Name]
+		if !ok || dependents[node] > dependents[current] {
+			hoisted[node.Name] = node
+		} else if dependents[node] == dependents[current] {
+			if newer, _ := ver.Satisfies(node.Version, ">"+current.Version); newer {
+				hoisted[node.Name] = node
+			}
+		}
+	}
+
+	var queue []*Placement
+	for _, name := range sortedNames(hoisted) {
+		queue = append(queue, newPlacement(root, hoisted[name]))
+	}
+
+	place(root, queue)
+	return root
+}
@@ -0,0 +1,154 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/bin"
+	"github.com/LOTaher/grog/internal/cache"
+	"github.com/LOTaher/grog/internal/resolver"
+	"github.com/LOTaher/grog/internal/symlink"
+)
+
+// StoreEntry is the virtual store directory of a package. The package itself
+// lives in its node_modules next to symlinks to each of its dependencies, so
+// Node's regular resolution finds them from the package's real path.
+func StoreEntry(dir string, node *resolver.Node) string {
+	key := strings.ReplaceAll(node.Name, "/", "+") + "@" + node.Version
+	return filepath.Join(dir, ModulesDir, StoreDir, key)
+}
+
+func PackagePath(dir string, node *resolver.Node) string {
+	return filepath.Join(StoreEntry(dir, node), ModulesDir, node.Name)
+}
+
+func linkIsolated(dir string, graph *resolver.Graph, method string) error {
+	modulesDir := filepath.Join(dir, ModulesDir)
+	storeDir := filepath.Join(modulesDir, StoreDir)
+	if err := os.MkdirAll(storeDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create the virtual store: %w", err)
+	}
+
+	entries := make(map[string]bool)
+	for _, node := range graph.Sorted() {
+		entries[filepath.Base(StoreEntry(dir, node))] = true
+	}
+
+	if err := pruneStore(storeDir, entries); err != nil {
+		return err
+	}
+
+	roots := make(map[string]bool)
+	for name := range graph.Edges {
+		roots[name] = true
+	}
+
+	if err := prune(modulesDir, roots); err != nil {
+		return err
+	}
+
+	for _, node := range graph.Sorted() {
+		if err := materialize(storeDir, PackagePath(dir, node), node, method); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range graph.Sorted() {
+		dependencies := make(map[string]string)
+		for _, name := range sortedNames(node.Edges) {
+			if name == node.Name {
+				continue
+			}
+
+			dependencies[name] = PackagePath(dir, node.Edges[name])
+			linkPath := filepath.Join(StoreEntry(dir, node), ModulesDir, name)
+			if err := linkRelative(dependencies[name], linkPath); err != nil {
+				return err
+			}
+		}
+
+		if err := bin.Link(filepath.Join(StoreEntry(dir, node), ModulesDir), dependencies); err != nil {
+			return err
+		}
+	}
+
+	direct := make(map[string]string)
+	for _, name := range sortedNames(graph.Edges) {
+		direct[name] = PackagePath(dir, graph.Edges[name])
+		if err := linkRelative(direct[name], filepath.Join(modulesDir, name)); err != nil {
+			return err
+		}
+	}
+
+	return bin.Link(modulesDir, direct)
+}
+
+// pruneStore removes virtual store entries that are not kept. Entries are
+// flat, scoped packages included, so unlike prune it never descends into
+// names starting with @.
+func pruneStore(storeDir string, keep map[string]bool) error {
+	entries, err := os.ReadDir(storeDir)
+	if err != nil {
+		return fmt.Errorf("failed to read the virtual store: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || keep[name] {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(storeDir, name)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func materialize(storeDir, path string, node *resolver.Node, method string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(storeDir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := cache.Materialize(node.Name, node.Version, staging, method); err != nil {
+		return fmt.Errorf("failed to materialize %s@%s: %w", node.Name, node.Version, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	if err := os.Rename(staging, path); err != nil {
+		return fmt.Errorf("failed to move %s@%s into the virtual store: %w", node.Name, node.Version, err)
+	}
+
+	return nil
+}
+
+func linkRelative(target, symlinkPath string) error {
+	if err := os.MkdirAll(filepath.Dir(symlinkPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(symlinkPath), err)
+	}
+
+	relative, err := filepath.Rel(filepath.Dir(symlinkPath), target)
+	if err != nil {
+		return err
+	}
+
+	if current, err := os.Readlink(symlinkPath); err == nil && current == relative {
+		return nil
+	}
+
+	return symlink.Link(relative, symlinkPath)
+}
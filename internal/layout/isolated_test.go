@@ -0,0 +1,45 @@
+package layout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneStoreKeepsScopedEntries(t *testing.T) {
+	storeDir := t.TempDir()
+
+	files := []string{
+		"@swc+core@1.3.0/node_modules/@swc/core/index.js",
+		"@old+pkg@1.0.0/node_modules/@old/pkg/index.js",
+		"a@1.0.0/node_modules/a/index.js",
+		"b@1.0.0/node_modules/b/index.js",
+		".tmp-123/index.js",
+	}
+	for _, file := range files {
+		path := filepath.Join(storeDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keep := map[string]bool{"@swc+core@1.3.0": true, "a@1.0.0": true}
+	if err := pruneStore(storeDir, keep); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{files[0], files[2], files[4]} {
+		if _, err := os.Stat(filepath.Join(storeDir, file)); err != nil {
+			t.Errorf("%s was removed: %v", file, err)
+		}
+	}
+
+	for _, entry := range []string{"@old+pkg@1.0.0", "b@1.0.0"} {
+		if _, err := os.Stat(filepath.Join(storeDir, entry)); !os.IsNotExist(err) {
+			t.Errorf("%s was kept", entry)
+		}
+	}
+}
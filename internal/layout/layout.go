@@ -0,0 +1,125 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/resolver"
+)
+
+const (
+	ModulesDir = "node_modules"
+	StoreDir   = ".grog"
+)
+
+const (
+	Isolated = "isolated"
+	Hoisted  = "hoisted"
+	Nested   = "nested"
+)
+
+var Modes = []string{Isolated, Hoisted, Nested}
+
+func Valid(mode string) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func Install(dir, mode, method string, graph *resolver.Graph) error {
+	switch mode {
+	case Isolated:
+		return linkIsolated(dir, graph, method)
+	case Hoisted, Nested:
+		if err := os.RemoveAll(filepath.Join(dir, ModulesDir, StoreDir)); err != nil {
+			return fmt.Errorf("failed to remove the virtual store: %w", err)
+		}
+		if mode == Hoisted {
+			return linkTree(dir, HoistedTree(graph), method)
+		}
+		return linkTree(dir, NestedTree(graph), method)
+	default:
+		return fmt.Errorf("unknown layout '%s', expected one of: %s", mode, strings.Join(Modes, ", "))
+	}
+}
+
+// Locations returns every directory a package is installed in for the given
+// mode. Nested and hoisted layouts can place a package more than once.
+func Locations(dir, mode string, graph *resolver.Graph) map[*resolver.Node][]string {
+	locations := make(map[*resolver.Node][]string)
+
+	if mode == Isolated {
+		for _, node := range graph.Sorted() {
+			locations[node] = []string{PackagePath(dir, node)}
+		}
+		return locations
+	}
+
+	root := NestedTree(graph)
+	if mode == Hoisted {
+		root = HoistedTree(graph)
+	}
+
+	root.Walk(func(p *Placement) error {
+		locations[p.Node] = append(locations[p.Node], filepath.Join(dir, p.Path))
+		return nil
+	})
+
+	return locations
+}
+
+func prune(modulesDir string, keep map[string]bool) error {
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		return fmt.Errorf("failed to read node_modules: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if strings.HasPrefix(name, "@") && entry.IsDir() {
+			scoped, err := os.ReadDir(filepath.Join(modulesDir, name))
+			if err != nil {
+				return fmt.Errorf("failed to read node_modules: %w", err)
+			}
+
+			for _, scopedEntry := range scoped {
+				scopedName := name + "/" + scopedEntry.Name()
+				if !keep[scopedName] {
+					if err := os.RemoveAll(filepath.Join(modulesDir, name, scopedEntry.Name())); err != nil {
+						return fmt.Errorf("failed to remove %s: %w", scopedName, err)
+					}
+				}
+			}
+			continue
+		}
+
+		if !keep[name] {
+			if err := os.RemoveAll(filepath.Join(modulesDir, name)); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,19 @@
+package layout
+
+import "github.com/LOTaher/grog/internal/resolver"
+
+// NestedTree places every package as high in the tree as it can go without
+// shadowing a different version that an ancestor already resolves to, so
+// conflicting versions end up in the node_modules of the package that needs
+// them.
+func NestedTree(graph *resolver.Graph) *Placement {
+	root := newPlacement(nil, nil)
+
+	var queue []*Placement
+	for _, name := range sortedNames(graph.Edges) {
+		queue = append(queue, newPlacement(root, graph.Edges[name]))
+	}
+
+	place(root, queue)
+	return root
+}
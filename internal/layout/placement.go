@@ -0,0 +1,175 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/LOTaher/grog/internal/bin"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
+)
+
+type Placement struct {
+	Path     string
+	Node     *resolver.Node
+	Children map[string]*Placement
+	parent   *Placement
+}
+
+func newPlacement(parent *Placement, node *resolver.Node) *Placement {
+	p := &Placement{
+		Node:     node,
+		Children: make(map[string]*Placement),
+		parent:   parent,
+	}
+
+	if parent != nil {
+		p.Path = filepath.Join(parent.Path, ModulesDir, node.Name)
+		parent.Children[node.Name] = p
+	}
+
+	return p
+}
+
+func place(root *Placement, queue []*Placement) {
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, name := range sortedNames(current.Node.Edges) {
+			dependency := current.Node.Edges[name]
+
+			// A cycle through conflicting versions would otherwise nest a new
+			// copy at every level, so stop at a package already placed above.
+			if current.holds(dependency) {
+				continue
+			}
+
+			target := root
+			for scope := current; scope != nil; scope = scope.parent {
+				existing, ok := scope.Children[name]
+				if !ok {
+					continue
+				}
+
+				if existing.Node == dependency {
+					target = nil
+				} else {
+					target = current
+				}
+				break
+			}
+
+			if target != nil {
+				queue = append(queue, newPlacement(target, dependency))
+			}
+		}
+	}
+}
+
+// holds reports whether the placement or one of its ancestors is the node.
+func (p *Placement) holds(node *resolver.Node) bool {
+	for ; p != nil; p = p.parent {
+		if p.Node == node {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Placement) Walk(fn func(*Placement) error) error {
+	for _, name := range sortedNames(p.Children) {
+		child := p.Children[name]
+		if err := fn(child); err != nil {
+			return err
+		}
+		if err := child.Walk(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkTree materializes every placement as a real directory imported from
+// the cache.
+func linkTree(dir string, root *Placement, method string) error {
+	modulesDir := filepath.Join(dir, ModulesDir)
+	if err := os.MkdirAll(modulesDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create node_modules directory: %w", err)
+	}
+
+	keep := make(map[string]bool)
+	for name := range root.Children {
+		keep[name] = true
+	}
+
+	if err := prune(modulesDir, keep); err != nil {
+		return err
+	}
+
+	// A directory that already holds the right version is kept, along with
+	// anything its install scripts built, and only its nested node_modules
+	// is pruned. Anything else is replaced.
+	err := root.Walk(func(p *Placement) error {
+		path := filepath.Join(dir, p.Path)
+		if holdsVersion(path, p.Node) {
+			return pruneNested(path, p)
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+
+		return materialize(modulesDir, path, p.Node, method)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := linkBins(dir, root); err != nil {
+		return err
+	}
+
+	return root.Walk(func(p *Placement) error {
+		if len(p.Children) == 0 {
+			return nil
+		}
+
+		return linkBins(dir, p)
+	})
+}
+
+func holdsVersion(path string, node *resolver.Node) bool {
+	pkg, err := manifest.Read(path)
+	if err != nil {
+		return false
+	}
+
+	return pkg.String("name") == node.Name && pkg.String("version") == node.Version
+}
+
+func pruneNested(path string, p *Placement) error {
+	modulesDir := filepath.Join(path, ModulesDir)
+	if _, err := os.Stat(modulesDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	keep := make(map[string]bool)
+	for name := range p.Children {
+		keep[name] = true
+	}
+
+	return prune(modulesDir, keep)
+}
+
+func linkBins(dir string, p *Placement) error {
+	packages := make(map[string]string)
+	for name, child := range p.Children {
+		packages[name] = filepath.Join(dir, child.Path)
+	}
+
+	return bin.Link(filepath.Join(dir, p.Path, ModulesDir), packages)
+}
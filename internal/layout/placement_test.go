@@ -0,0 +1,60 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/LOTaher/grog/internal/resolver"
+)
+
+// cycleGraph builds a@1 -> b@1 -> a@2 -> b@2 -> a@1, where every step needs
+// a version that conflicts with one placed above it.
+func cycleGraph() *resolver.Graph {
+	a1 := &resolver.Node{Name: "a", Version: "1.0.0", Edges: map[string]*resolver.Node{}}
+	b1 := &resolver.Node{Name: "b", Version: "1.0.0", Edges: map[string]*resolver.Node{}}
+	a2 := &resolver.Node{Name: "a", Version: "2.0.0", Edges: map[string]*resolver.Node{}}
+	b2 := &resolver.Node{Name: "b", Version: "2.0.0", Edges: map[string]*resolver.Node{}}
+
+	a1.Edges["b"] = b1
+	b1.Edges["a"] = a2
+	a2.Edges["b"] = b2
+	b2.Edges["a"] = a1
+
+	graph := &resolver.Graph{
+		Dependencies: map[string]string{"a": "1.0.0"},
+		Edges:        map[string]*resolver.Node{"a": a1},
+		Nodes:        map[string]*resolver.Node{},
+	}
+	for _, node := range []*resolver.Node{a1, b1, a2, b2} {
+		graph.Nodes[resolver.Key(node.Name, node.Version)] = node
+	}
+
+	return graph
+}
+
+func TestTreesTerminateOnCycles(t *testing.T) {
+	trees := map[string]func(*resolver.Graph) *Placement{
+		Nested:  NestedTree,
+		Hoisted: HoistedTree,
+	}
+
+	for mode, tree := range trees {
+		t.Run(mode, func(t *testing.T) {
+			graph := cycleGraph()
+
+			placed := make(map[*resolver.Node]bool)
+			count := 0
+			tree(graph).Walk(func(p *Placement) error {
+				placed[p.Node] = true
+				count++
+				return nil
+			})
+
+			if len(placed) != len(graph.Nodes) {
+				t.Errorf("placed %d of %d packages", len(placed), len(graph.Nodes))
+			}
+			if count > 2*len(graph.Nodes) {
+				t.Errorf("placed %d copies of %d packages", count, len(graph.Nodes))
+			}
+		})
+	}
+}
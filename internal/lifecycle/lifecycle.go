@@ -0,0 +1,262 @@
+package lifecycle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/LOTaher/grog/internal/cache"
+	"github.com/LOTaher/grog/internal/layout"
+	"github.com/LOTaher/grog/internal/manifest"
+	"github.com/LOTaher/grog/internal/resolver"
+	"github.com/LOTaher/grog/internal/script"
+	ver "github.com/LOTaher/grog/internal/version"
+)
+
+var Events = []string{"preinstall", "install", "postinstall"}
+
+const (
+	BuiltMarker = ".grog-built"
+	LogDir      = ".grog-logs"
+)
+
+type Policy struct {
+	Allow []string
+	Deny  []string
+}
+
+// Allowed reports whether a package may run its install scripts. Entries are
+// package names, optionally followed by @range, or "*" for every package.
+// Denied packages win over allowed ones.
+func (p Policy) Allowed(node *resolver.Node) bool {
+	return !matches(p.Deny, node) && matches(p.Allow, node)
+}
+
+func matches(patterns []string, node *resolver.Node) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+
+		name, spec := pattern, ""
+		if i := strings.LastIndex(pattern, "@"); i > 0 {
+			name, spec = pattern[:i], pattern[i+1:]
+		}
+
+		if name != node.Name {
+			continue
+		}
+
+		if spec == "" {
+			return true
+		}
+
+		if ok, _ := ver.Satisfies(node.Version, spec); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+type job struct {
+	node   *resolver.Node
+	pkg    *manifest.Manifest
+	dirs   []string
+	events []string
+}
+
+// Run runs the install scripts of every allowed package in the graph, after
+// the scripts of everything it depends on, with at most concurrency scripts
+// running at once.
+func Run(dir string, graph *resolver.Graph, locations map[*resolver.Node][]string, policy Policy, concurrency int) error {
+	pending := make(map[*resolver.Node]*job)
+	var skipped []string
+
+	for _, node := range graph.Sorted() {
+		for _, location := range locations[node] {
+			if _, err := os.Stat(filepath.Join(location, BuiltMarker)); err == nil {
+				continue
+			}
+
+			pkg, err := manifest.Read(location)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s@%s: %w", node.Name, node.Version, err)
+			}
+
+			scripts, err := pkg.Scripts()
+			if err != nil {
+				return fmt.Errorf("failed to read scripts of %s@%s: %w", node.Name, node.Version, err)
+			}
+
+			var events []string
+			for _, event := range Events {
+				if _, ok := scripts[event]; ok {
+					events = append(events, event)
+				}
+			}
+			if len(events) == 0 {
+				continue
+			}
+
+			if !policy.Allowed(node) {
+				skipped = append(skipped, resolver.Key(node.Name, node.Version))
+				break
+			}
+
+			if pending[node] == nil {
+				pending[node] = &job{node: node, pkg: pkg, events: events}
+			}
+			pending[node].dirs = append(pending[node].dirs, location)
+		}
+	}
+
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped install scripts of %s. Scripts only run for packages listed in \"allowScripts\" and not in \"denyScripts\" in the \"grog\" field of package.json.\n", strings.Join(skipped, ", "))
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	for len(pending) > 0 {
+		var wave []*job
+		for node, j := range pending {
+			if !waitsOn(node, pending, make(map[*resolver.Node]bool)) {
+				wave = append(wave, j)
+			}
+		}
+
+		// Only a dependency cycle leaves nothing ready; run the rest together.
+		if len(wave) == 0 {
+			for _, j := range pending {
+				wave = append(wave, j)
+			}
+		}
+
+		sort.Slice(wave, func(i, k int) bool {
+			return resolver.Key(wave[i].node.Name, wave[i].node.Version) < resolver.Key(wave[k].node.Name, wave[k].node.Version)
+		})
+
+		for _, j := range wave {
+			delete(pending, j.node)
+		}
+
+		if err := runWave(dir, wave, concurrency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func waitsOn(node *resolver.Node, pending map[*resolver.Node]*job, seen map[*resolver.Node]bool) bool {
+	for _, dependency := range node.Edges {
+		if dependency == node || seen[dependency] {
+			continue
+		}
+		seen[dependency] = true
+
+		if pending[dependency] != nil || waitsOn(dependency, pending, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runWave(dir string, wave []*job, concurrency int) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(wave))
+	slots := make(chan struct{}, concurrency)
+
+	for _, j := range wave {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if err := runJob(dir, j); err != nil {
+				errChan <- err
+			}
+		}(j)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runJob(dir string, j *job) error {
+	key := resolver.Key(j.node.Name, j.node.Version)
+	logPath := filepath.Join(dir, layout.ModulesDir, LogDir, strings.ReplaceAll(key, "/", "+")+".log")
+
+	var output bytes.Buffer
+	var runErr error
+
+run:
+	for _, location := range j.dirs {
+		if err := cache.Detach(location); err != nil {
+			runErr = fmt.Errorf("failed to prepare %s for its scripts: %w", key, err)
+			break
+		}
+
+		for _, event := range j.events {
+			command, err := script.Command(location, j.pkg, event, nil)
+			if err != nil {
+				runErr = err
+				break run
+			}
+
+			fmt.Fprintf(&output, "> %s %s\n> %s\n", key, event, command.Args[len(command.Args)-1])
+			command.Stdout = &output
+			command.Stderr = &output
+
+			if err := command.Run(); err != nil {
+				runErr = fmt.Errorf("%s script of %s failed: %w", event, key, err)
+				break run
+			}
+		}
+
+		if err := os.WriteFile(filepath.Join(location, BuiltMarker), nil, 0644); err != nil {
+			runErr = fmt.Errorf("failed to mark %s as built: %w", key, err)
+			break
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(logPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(logPath), err)
+	}
+	if err := os.WriteFile(logPath, output.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", logPath, err)
+	}
+
+	if runErr != nil {
+		lines := strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
+		if len(lines) > 20 {
+			lines = lines[len(lines)-20:]
+		}
+		return fmt.Errorf("%w\n%s\nFull log: %s", runErr, strings.Join(lines, "\n"), logPath)
+	}
+
+	fmt.Printf("Ran %s scripts of %s\n", strings.Join(j.events, ", "), key)
+	return nil
+}
@@ -0,0 +1,181 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/LOTaher/grog/internal/resolver"
+)
+
+const (
+	FileName = "grog.lock"
+	Version  = 1
+)
+
+type Package struct {
+	Name             string            `json:"name"`
+	Version          string            `json:"version"`
+	Resolved         string            `json:"resolved"`
+	Integrity        string            `json:"integrity,omitempty"`
+	Requires         map[string]string `json:"requires,omitempty"`
+	OptionalRequires map[string]string `json:"optionalRequires,omitempty"`
+	Dependencies     map[string]string `json:"dependencies,omitempty"`
+}
+
+type LockFile struct {
+	LockfileVersion int                `json:"lockfileVersion"`
+	Requires        map[string]string  `json:"requires"`
+	Dependencies    map[string]string  `json:"dependencies"`
+	Packages        map[string]Package `json:"packages"`
+}
+
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, FileName))
+	return err == nil
+}
+
+func Read(dir string) (*LockFile, error) {
+	file, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+
+	var lockFile LockFile
+	if err := json.Unmarshal(file, &lockFile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", FileName, err)
+	}
+
+	if lockFile.LockfileVersion != Version {
+		return nil, fmt.Errorf("unsupported %s version %d", FileName, lockFile.LockfileVersion)
+	}
+
+	return &lockFile, nil
+}
+
+func Write(dir string, lockFile *LockFile) error {
+	data, err := json.MarshalIndent(lockFile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", FileName, err)
+	}
+	data = append(data, '\n')
+
+	path := filepath.Join(dir, FileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+
+	return nil
+}
+
+func FromGraph(graph *resolver.Graph) *LockFile {
+	lockFile := &LockFile{
+		LockfileVersion: Version,
+		Requires:        make(map[string]string),
+		Dependencies:    make(map[string]string),
+		Packages:        make(map[string]Package),
+	}
+
+	for name, spec := range graph.Dependencies {
+		lockFile.Requires[name] = spec
+	}
+	for name, node := range graph.Edges {
+		lockFile.Dependencies[name] = node.Version
+	}
+
+	for key, node := range graph.Nodes {
+		pkg := Package{
+			Name:      node.Name,
+			Version:   node.Version,
+			Resolved:  node.Tarball,
+			Integrity: node.Integrity,
+		}
+
+		if len(node.Dependencies) > 0 {
+			pkg.Requires = make(map[string]string)
+			for name, spec := range node.Dependencies {
+				pkg.Requires[name] = spec
+			}
+		}
+
+		if len(node.Optional) > 0 {
+			pkg.OptionalRequires = make(map[string]string)
+			for name, spec := range node.Optional {
+				pkg.OptionalRequires[name] = spec
+			}
+		}
+
+		if len(node.Edges) > 0 {
+			pkg.Dependencies = make(map[string]string)
+			for name, dep := range node.Edges {
+				pkg.Dependencies[name] = dep.Version
+			}
+		}
+
+		lockFile.Packages[key] = pkg
+	}
+
+	return lockFile
+}
+
+func (l *LockFile) Graph() (*resolver.Graph, error) {
+	graph := &resolver.Graph{
+		Dependencies: make(map[string]string),
+		Edges:        make(map[string]*resolver.Node),
+		Nodes:        make(map[string]*resolver.Node),
+	}
+
+	for key, pkg := range l.Packages {
+		dependencies := make(map[string]string)
+		for name, spec := range pkg.Requires {
+			dependencies[name] = spec
+		}
+
+		optional := make(map[string]string)
+		for name, spec := range pkg.OptionalRequires {
+			optional[name] = spec
+		}
+
+		graph.Nodes[key] = &resolver.Node{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Tarball:      pkg.Resolved,
+			Integrity:    pkg.Integrity,
+			Dependencies: dependencies,
+			Optional:     optional,
+			Edges:        make(map[string]*resolver.Node),
+		}
+	}
+
+	for key, pkg := range l.Packages {
+		node := graph.Nodes[key]
+		for name, version := range pkg.Dependencies {
+			dep, ok := graph.Nodes[resolver.Key(name, version)]
+			if !ok {
+				return nil, fmt.Errorf("%s is missing %s required by %s", FileName, resolver.Key(name, version), key)
+			}
+			node.Edges[name] = dep
+		}
+	}
+
+	for name, spec := range l.Requires {
+		graph.Dependencies[name] = spec
+	}
+
+	for name, version := range l.Dependencies {
+		dep, ok := graph.Nodes[resolver.Key(name, version)]
+		if !ok {
+			return nil, fmt.Errorf("%s is missing %s", FileName, resolver.Key(name, version))
+		}
+		graph.Edges[name] = dep
+	}
+
+	return graph, nil
+}
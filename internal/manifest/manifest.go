@@ -0,0 +1,453 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const FileName = "package.json"
+
+var DependencyFields = []string{"dependencies", "devDependencies", "optionalDependencies", "peerDependencies"}
+
+type Config struct {
+	Layout       string   `json:"layout,omitempty"`
+	ImportMethod string   `json:"importMethod,omitempty"`
+	AllowScripts []string `json:"allowScripts,omitempty"`
+	DenyScripts  []string `json:"denyScripts,omitempty"`
+}
+
+// field keeps the bytes around a top-level key as they appear in the file:
+// the whitespace before the key, the separator between key and value and
+// the whitespace after the value.
+type field struct {
+	lead  []byte
+	key   []byte
+	colon []byte
+	name  string
+	value json.RawMessage
+	tail  []byte
+}
+
+type Manifest struct {
+	fields []field
+	prefix []byte
+	inner  []byte
+	suffix []byte
+	indent string
+	colon  string
+	eol    string
+}
+
+func New() *Manifest {
+	return &Manifest{
+		suffix: []byte("\n"),
+		indent: "  ",
+		colon:  ": ",
+		eol:    "\n",
+	}
+}
+
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+func Exists(dir string) bool {
+	_, err := os.Stat(Path(dir))
+	return err == nil
+}
+
+func Read(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(Path(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+
+	return m, nil
+}
+
+func Parse(data []byte) (*Manifest, error) {
+	m := New()
+
+	start := bytes.IndexByte(data, '{')
+	end := bytes.LastIndexByte(data, '}')
+	if start < 0 || end < start || len(bytes.TrimSpace(data[:start])) > 0 && !bytes.Equal(bytes.TrimSpace(data[:start]), []byte("\xef\xbb\xbf")) {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	if len(bytes.TrimSpace(data[end+1:])) > 0 {
+		return nil, fmt.Errorf("unexpected data after the top-level object")
+	}
+
+	m.prefix = append([]byte(nil), data[:start]...)
+	m.suffix = append([]byte(nil), data[end+1:]...)
+
+	if bytes.Contains(data, []byte("\r\n")) {
+		m.eol = "\r\n"
+	}
+
+	body := data[start:]
+	m.indent = detectIndent(body)
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	offset := dec.InputOffset()
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keyEnd := dec.InputOffset()
+
+		name, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an object key")
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		valueEnd := dec.InputOffset()
+		valueStart := valueEnd - int64(len(value))
+
+		between := body[offset:keyEnd]
+		if comma := bytes.IndexByte(between, ','); comma >= 0 && len(m.fields) > 0 {
+			m.fields[len(m.fields)-1].tail = append([]byte(nil), between[:comma]...)
+			between = between[comma+1:]
+		}
+		keyStart := bytes.IndexByte(between, '"')
+
+		f := field{
+			lead:  append([]byte(nil), between[:keyStart]...),
+			key:   append([]byte(nil), between[keyStart:]...),
+			colon: append([]byte(nil), body[keyEnd:valueStart]...),
+			name:  name,
+			value: value,
+		}
+		if len(m.fields) == 0 {
+			m.colon = string(f.colon)
+		}
+
+		m.fields = append(m.fields, f)
+		offset = valueEnd
+	}
+
+	closing := bytes.LastIndexByte(body, '}')
+	if len(m.fields) > 0 {
+		m.fields[len(m.fields)-1].tail = append([]byte(nil), body[offset:closing]...)
+	} else {
+		m.inner = append([]byte(nil), body[offset:closing]...)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the top-level object")
+	}
+
+	return m, nil
+}
+
+func detectIndent(body []byte) string {
+	newline := bytes.IndexByte(body, '\n')
+	if newline < 0 {
+		return ""
+	}
+
+	rest := body[newline+1:]
+	width := 0
+	for width < len(rest) && (rest[width] == ' ' || rest[width] == '\t') {
+		width++
+	}
+	if width == 0 {
+		return "  "
+	}
+
+	return string(rest[:width])
+}
+
+func (m *Manifest) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(m.prefix)
+	buf.WriteByte('{')
+
+	if len(m.fields) == 0 {
+		buf.Write(m.inner)
+	}
+
+	for i, f := range m.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(f.lead)
+		buf.Write(f.key)
+		buf.Write(f.colon)
+		buf.Write(f.value)
+		buf.Write(f.tail)
+	}
+
+	buf.WriteByte('}')
+	buf.Write(m.suffix)
+
+	return buf.Bytes()
+}
+
+func (m *Manifest) Write(dir string) error {
+	path := Path(dir)
+	tmpPath := path + ".tmp"
+
+	if err := os.WriteFile(tmpPath, m.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+
+	return nil
+}
+
+func (m *Manifest) Has(name string) bool {
+	return m.find(name) >= 0
+}
+
+func (m *Manifest) Get(name string, v interface{}) (bool, error) {
+	i := m.find(name)
+	if i < 0 {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(m.fields[i].value, v); err != nil {
+		return true, fmt.Errorf("invalid %s field: %w", name, err)
+	}
+
+	return true, nil
+}
+
+func (m *Manifest) Set(name string, v interface{}) error {
+	value, err := m.marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s field: %w", name, err)
+	}
+
+	if i := m.find(name); i >= 0 {
+		m.fields[i].value = value
+		return nil
+	}
+
+	key, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+
+	f := field{key: key, colon: []byte(m.colon), name: name, value: value}
+	if m.indent != "" {
+		f.lead = []byte(m.eol + m.indent)
+	}
+
+	// The new field becomes the last one and takes over the whitespace
+	// before the closing brace.
+	if last := len(m.fields) - 1; last >= 0 {
+		f.tail, m.fields[last].tail = m.fields[last].tail, nil
+	} else if m.indent != "" {
+		f.tail = []byte(m.eol)
+	}
+
+	m.fields = append(m.fields, f)
+	return nil
+}
+
+func (m *Manifest) Delete(name string) {
+	i := m.find(name)
+	if i < 0 {
+		return
+	}
+
+	if last := len(m.fields) - 1; i == last && i > 0 {
+		m.fields[i-1].tail = m.fields[i].tail
+	}
+
+	m.fields = append(m.fields[:i], m.fields[i+1:]...)
+	if len(m.fields) == 0 {
+		m.inner = nil
+	}
+}
+
+func (m *Manifest) String(name string) string {
+	var value string
+	if ok, err := m.Get(name, &value); !ok || err != nil {
+		return ""
+	}
+
+	return value
+}
+
+func (m *Manifest) Dependencies(field string) (map[string]string, error) {
+	dependencies := make(map[string]string)
+	if _, err := m.Get(field, &dependencies); err != nil {
+		return nil, err
+	}
+
+	return dependencies, nil
+}
+
+func (m *Manifest) SetDependency(field, name, spec string) error {
+	dependencies, err := m.Dependencies(field)
+	if err != nil {
+		return err
+	}
+
+	dependencies[name] = spec
+	return m.Set(field, dependencies)
+}
+
+func (m *Manifest) RemoveDependency(field, name string) (bool, error) {
+	dependencies, err := m.Dependencies(field)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := dependencies[name]; !ok {
+		return false, nil
+	}
+
+	delete(dependencies, name)
+	return true, m.Set(field, dependencies)
+}
+
+func (m *Manifest) AllDependencies() (map[string]string, error) {
+	all := make(map[string]string)
+	for _, field := range []string{"peerDependencies", "devDependencies", "optionalDependencies", "dependencies"} {
+		dependencies, err := m.Dependencies(field)
+		if err != nil {
+			return nil, err
+		}
+		for name, spec := range dependencies {
+			all[name] = spec
+		}
+	}
+
+	return all, nil
+}
+
+func (m *Manifest) Config() (Config, error) {
+	var config Config
+	if _, err := m.Get("grog", &config); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+func (m *Manifest) Scripts() (map[string]string, error) {
+	scripts := make(map[string]string)
+	if _, err := m.Get("scripts", &scripts); err != nil {
+		return nil, err
+	}
+
+	return scripts, nil
+}
+
+func (m *Manifest) Bins() (map[string]string, error) {
+	var single string
+	if ok, err := m.Get("bin", &single); ok && err == nil {
+		bins := make(map[string]string)
+		if name := m.String("name"); name != "" && single != "" {
+			bins[path.Base(name)] = single
+		}
+		return bins, nil
+	}
+
+	bins := make(map[string]string)
+	if _, err := m.Get("bin", &bins); err != nil {
+		return nil, err
+	}
+
+	return bins, nil
+}
+
+func (m *Manifest) BinDirectory() string {
+	var directories struct {
+		Bin string `json:"bin"`
+	}
+	if ok, err := m.Get("directories", &directories); !ok || err != nil {
+		return ""
+	}
+
+	return directories.Bin
+}
+
+func (m *Manifest) DevOnly() ([]string, error) {
+	dev, err := m.Dependencies("devDependencies")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range dev {
+		if m.DependencyType(name) == "devDependencies" {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+func (m *Manifest) DependencyType(name string) string {
+	for _, field := range []string{"dependencies", "optionalDependencies", "peerDependencies", "devDependencies"} {
+		dependencies, err := m.Dependencies(field)
+		if err != nil {
+			continue
+		}
+		if _, ok := dependencies[name]; ok {
+			return field
+		}
+	}
+
+	return ""
+}
+
+func (m *Manifest) find(name string) int {
+	for i, f := range m.fields {
+		if f.name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (m *Manifest) marshal(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if m.indent != "" {
+		enc.SetIndent(m.indent, m.indent)
+	}
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	value := strings.TrimRight(buf.String(), "\n")
+	if m.eol != "\n" {
+		value = strings.ReplaceAll(value, "\n", m.eol)
+	}
+
+	return json.RawMessage(value), nil
+}
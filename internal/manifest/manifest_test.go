@@ -0,0 +1,125 @@
+package manifest
+
+import (
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	fixtures := []string{
+		"{\n  \"name\": \"x\",\n  \"version\": \"1.0.0\"\n}\n",
+		"{\n  \"name\":\"x\",\n  \"version\" : \"1.0.0\",\n  \"private\":   true\n}\n",
+		"{\r\n\t\"name\": \"x\",\r\n\t\"dependencies\": {\r\n\t\t\"a\": \"^1.0.0\"\r\n\t}\r\n}",
+		"{\"name\":\"x\",\"version\":\"1.0.0\"}",
+		"{\n    \"name\": \"x\" ,\n    \"version\": \"1.0.0\"   \n}",
+		"\xef\xbb\xbf{\n  \"name\": \"x\"\n}\n",
+		"{\n  \"z\": 1,\n  \"a\": 2,\n  \"m\": 3\n}\n\n",
+		"{}",
+		"{\n}\n",
+	}
+
+	for _, fixture := range fixtures {
+		m, err := Parse([]byte(fixture))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", fixture, err)
+			continue
+		}
+
+		if got := string(m.Bytes()); got != fixture {
+			t.Errorf("round trip changed the file:\n got %q\nwant %q", got, fixture)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		edit  func(*Manifest) error
+		want  string
+	}{
+		{
+			name:  "set dependency with mixed colon spacing",
+			input: "{\n  \"name\":\"x\",\n  \"version\" : \"1.0.0\",\n  \"dependencies\": {\n    \"a\": \"^1.0.0\"\n  },\n  \"scripts\":   {\"test\": \"t\"}\n}\n",
+			edit: func(m *Manifest) error {
+				return m.SetDependency("dependencies", "b", "^2.0.0")
+			},
+			want: "{\n  \"name\":\"x\",\n  \"version\" : \"1.0.0\",\n  \"dependencies\": {\n    \"a\": \"^1.0.0\",\n    \"b\": \"^2.0.0\"\n  },\n  \"scripts\":   {\"test\": \"t\"}\n}\n",
+		},
+		{
+			name:  "set dependency with CRLF, tabs and no final newline",
+			input: "{\r\n\t\"name\": \"x\",\r\n\t\"dependencies\": {\r\n\t\t\"a\": \"^1.0.0\"\r\n\t}\r\n}",
+			edit: func(m *Manifest) error {
+				return m.SetDependency("dependencies", "b", "^2.0.0")
+			},
+			want: "{\r\n\t\"name\": \"x\",\r\n\t\"dependencies\": {\r\n\t\t\"a\": \"^1.0.0\",\r\n\t\t\"b\": \"^2.0.0\"\r\n\t}\r\n}",
+		},
+		{
+			name:  "add a field",
+			input: "{\r\n\t\"name\": \"x\",\r\n\t\"version\" :\"1.0.0\"\r\n}",
+			edit: func(m *Manifest) error {
+				return m.SetDependency("devDependencies", "c", "1.0.0")
+			},
+			want: "{\r\n\t\"name\": \"x\",\r\n\t\"version\" :\"1.0.0\",\r\n\t\"devDependencies\": {\r\n\t\t\"c\": \"1.0.0\"\r\n\t}\r\n}",
+		},
+		{
+			name:  "add a field to a minified file",
+			input: "{\"name\":\"x\"}",
+			edit: func(m *Manifest) error {
+				return m.Set("version", "1.0.0")
+			},
+			want: "{\"name\":\"x\",\"version\":\"1.0.0\"}",
+		},
+		{
+			name:  "add a field to an empty file",
+			input: "{}\n",
+			edit: func(m *Manifest) error {
+				return m.Set("name", "x")
+			},
+			want: "{\n  \"name\": \"x\"\n}\n",
+		},
+		{
+			name:  "delete the last field",
+			input: "{\n  \"name\": \"x\",\n  \"version\":\"1.0.0\",\n  \"private\": true\n}\n",
+			edit: func(m *Manifest) error {
+				m.Delete("private")
+				return nil
+			},
+			want: "{\n  \"name\": \"x\",\n  \"version\":\"1.0.0\"\n}\n",
+		},
+		{
+			name:  "delete a middle field",
+			input: "{\n  \"z\": 1,\n  \"a\" : 2,\n  \"m\":3\n}",
+			edit: func(m *Manifest) error {
+				m.Delete("a")
+				return nil
+			},
+			want: "{\n  \"z\": 1,\n  \"m\":3\n}",
+		},
+		{
+			name:  "remove a dependency keeps key order",
+			input: "{\n  \"z\": 1,\n  \"dependencies\": {\n    \"a\": \"1\",\n    \"b\": \"2\"\n  },\n  \"a\": 2\n}\n",
+			edit: func(m *Manifest) error {
+				_, err := m.RemoveDependency("dependencies", "a")
+				return err
+			},
+			want: "{\n  \"z\": 1,\n  \"dependencies\": {\n    \"b\": \"2\"\n  },\n  \"a\": 2\n}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		m, err := Parse([]byte(tc.input))
+		if err != nil {
+			t.Errorf("%s: Parse: %v", tc.name, err)
+			continue
+		}
+
+		if err := tc.edit(m); err != nil {
+			t.Errorf("%s: edit: %v", tc.name, err)
+			continue
+		}
+
+		if got := string(m.Bytes()); got != tc.want {
+			t.Errorf("%s:\n got %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -8,11 +8,16 @@ import (
 )
 
 type Response struct {
-	Name         string            `json:"name"`
-	Version      string            `json:"version"`
-	Dependencies map[string]string `json:"dependencies"`
-	Dist         struct {
-		Tarball string `json:"tarball"`
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	OS                   []string          `json:"os"`
+	CPU                  []string          `json:"cpu"`
+	Dist                 struct {
+		Tarball   string `json:"tarball"`
+		Integrity string `json:"integrity"`
+		Shasum    string `json:"shasum"`
 	} `json:"dist"`
 }
 
@@ -0,0 +1,524 @@
+package resolver
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/LOTaher/grog/internal/integrity"
+	"github.com/LOTaher/grog/internal/request"
+	ver "github.com/LOTaher/grog/internal/version"
+	"github.com/Masterminds/semver/v3"
+)
+
+type Node struct {
+	Name         string
+	Version      string
+	Tarball      string
+	Integrity    string
+	Latest       bool
+	Dependencies map[string]string
+	// Optional holds optionalDependencies. They only get an edge when they
+	// resolve and support the current platform.
+	Optional map[string]string
+	Edges    map[string]*Node
+}
+
+type Graph struct {
+	Dependencies map[string]string
+	Edges        map[string]*Node
+	Nodes        map[string]*Node
+}
+
+type Conflict struct {
+	Name     string
+	Versions []string
+}
+
+type edge struct {
+	parent   *Node
+	name     string
+	spec     string
+	optional bool
+}
+
+type resolution struct {
+	mu       sync.Mutex
+	registry map[string]ver.Version
+	failed   map[string]error
+	locked   *Graph
+	graph    *Graph
+}
+
+func Key(name, version string) string {
+	return name + "@" + version
+}
+
+func Resolve(dependencies map[string]string, locked *Graph) (*Graph, error) {
+	r := &resolution{
+		registry: make(map[string]ver.Version),
+		failed:   make(map[string]error),
+		locked:   locked,
+		graph: &Graph{
+			Dependencies: dependencies,
+			Edges:        make(map[string]*Node),
+			Nodes:        make(map[string]*Node),
+		},
+	}
+
+	var pending []edge
+	for name, spec := range dependencies {
+		pending = append(pending, edge{name: name, spec: spec})
+	}
+
+	for len(pending) > 0 {
+		sort.Slice(pending, func(i, j int) bool {
+			if pending[i].name != pending[j].name {
+				return pending[i].name < pending[j].name
+			}
+			return pending[i].spec < pending[j].spec
+		})
+
+		// Lockfiles written before integrity was recorded need the registry
+		// to fill it in.
+		var unlocked []edge
+		for _, e := range pending {
+			if locked := r.lockedNode(e); locked == nil || locked.Integrity == "" {
+				unlocked = append(unlocked, e)
+			}
+		}
+
+		if err := r.prefetch(unlocked); err != nil {
+			return nil, err
+		}
+
+		var next []edge
+		for _, e := range pending {
+			node, created, err := r.resolveEdge(e)
+			if err != nil && e.optional {
+				fmt.Printf("Skipping optional dependency %s@%s of %s: %v\n", e.name, e.spec, Key(e.parent.Name, e.parent.Version), err)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if node == nil {
+				continue
+			}
+
+			if e.parent == nil {
+				r.graph.Edges[e.name] = node
+			} else {
+				e.parent.Edges[e.name] = node
+			}
+
+			if created {
+				for depName, depSpec := range node.Dependencies {
+					next = append(next, edge{parent: node, name: depName, spec: depSpec})
+				}
+				for depName, depSpec := range node.Optional {
+					next = append(next, edge{parent: node, name: depName, spec: depSpec, optional: true})
+				}
+			}
+		}
+
+		pending = next
+	}
+
+	return r.graph, nil
+}
+
+func (r *resolution) prefetch(pending []edge) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pending))
+	seen := make(map[string]bool)
+
+	// Metadata that fails to load only stops the install when a required
+	// edge needs it; optional edges are skipped by resolveEdge instead.
+	required := make(map[string]bool)
+	for _, e := range pending {
+		if !e.optional {
+			required[e.name] = true
+		}
+	}
+
+	for _, e := range pending {
+		if seen[e.name] {
+			continue
+		}
+		seen[e.name] = true
+
+		r.mu.Lock()
+		_, fetched := r.registry[e.name]
+		_, failed := r.failed[e.name]
+		r.mu.Unlock()
+		if fetched || failed {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			versions, err := ver.FetchVersions(name)
+			if err != nil {
+				err = fmt.Errorf("failed to fetch metadata for %s: %w", name, err)
+
+				r.mu.Lock()
+				r.failed[name] = err
+				r.mu.Unlock()
+
+				if required[name] {
+					errChan <- err
+				}
+				return
+			}
+
+			r.mu.Lock()
+			r.registry[name] = versions
+			r.mu.Unlock()
+		}(e.name)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *resolution) resolveEdge(e edge) (*Node, bool, error) {
+	if node := r.existing(e.name, e.spec); node != nil {
+		return node, false, nil
+	}
+
+	if locked := r.lockedNode(e); locked != nil {
+		key := Key(locked.Name, locked.Version)
+		if node, ok := r.graph.Nodes[key]; ok {
+			return node, false, nil
+		}
+
+		node := &Node{
+			Name:         locked.Name,
+			Version:      locked.Version,
+			Tarball:      locked.Tarball,
+			Integrity:    locked.Integrity,
+			Dependencies: locked.Dependencies,
+			Optional:     locked.Optional,
+			Edges:        make(map[string]*Node),
+		}
+
+		if node.Integrity == "" {
+			manifest, ok := r.registry[e.name].Versions[node.Version]
+			if !ok {
+				return nil, false, fmt.Errorf("registry has no manifest for %s", key)
+			}
+
+			integrity, err := distIntegrity(manifest)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid shasum for %s: %w", key, err)
+			}
+			node.Integrity = integrity
+		}
+
+		r.graph.Nodes[key] = node
+
+		return node, true, nil
+	}
+
+	if err := r.failed[e.name]; err != nil {
+		return nil, false, err
+	}
+
+	versions := r.registry[e.name]
+
+	resolved, err := versions.Resolve(e.spec)
+	if err != nil {
+		if e.parent != nil {
+			return nil, false, fmt.Errorf("unable to resolve %s@%s required by %s: %w", e.name, e.spec, Key(e.parent.Name, e.parent.Version), err)
+		}
+		return nil, false, fmt.Errorf("unable to resolve %s@%s: %w", e.name, e.spec, err)
+	}
+
+	key := Key(e.name, resolved)
+	if node, ok := r.graph.Nodes[key]; ok {
+		return node, false, nil
+	}
+
+	manifest, ok := versions.Versions[resolved]
+	if !ok {
+		return nil, false, fmt.Errorf("registry has no manifest for %s", key)
+	}
+
+	if e.optional && !supported(manifest) {
+		return nil, false, nil
+	}
+
+	// Packages may list an optional dependency in dependencies as well, in
+	// which case it is still optional.
+	dependencies := make(map[string]string)
+	for name, spec := range manifest.Dependencies {
+		if _, ok := manifest.OptionalDependencies[name]; !ok {
+			dependencies[name] = spec
+		}
+	}
+
+	optional := make(map[string]string)
+	for name, spec := range manifest.OptionalDependencies {
+		optional[name] = spec
+	}
+
+	integrity, err := distIntegrity(manifest)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid shasum for %s: %w", key, err)
+	}
+
+	node := &Node{
+		Name:         e.name,
+		Version:      resolved,
+		Tarball:      manifest.Dist.Tarball,
+		Integrity:    integrity,
+		Latest:       versions.Latest() == resolved,
+		Dependencies: dependencies,
+		Optional:     optional,
+		Edges:        make(map[string]*Node),
+	}
+	r.graph.Nodes[key] = node
+
+	return node, true, nil
+}
+
+func (r *resolution) lockedNode(e edge) *Node {
+	if r.locked == nil {
+		return nil
+	}
+
+	var candidate *Node
+	if e.parent == nil {
+		candidate = r.locked.Edges[e.name]
+	} else if parent, ok := r.locked.Nodes[Key(e.parent.Name, e.parent.Version)]; ok {
+		candidate = parent.Edges[e.name]
+	}
+	if candidate == nil {
+		return nil
+	}
+
+	if !ver.ValidRange(e.spec) {
+		if e.parent == nil && r.locked.Dependencies[e.name] == e.spec {
+			return candidate
+		}
+		return nil
+	}
+
+	if ok, err := ver.Satisfies(candidate.Version, e.spec); err != nil || !ok {
+		return nil
+	}
+
+	return candidate
+}
+
+func (r *resolution) existing(name, spec string) *Node {
+	var best *Node
+	var bestVersion *semver.Version
+	for _, node := range r.graph.Nodes {
+		if node.Name != name {
+			continue
+		}
+
+		if ok, err := ver.Satisfies(node.Version, spec); err != nil || !ok {
+			continue
+		}
+
+		v, err := semver.NewVersion(node.Version)
+		if err != nil {
+			continue
+		}
+
+		if bestVersion == nil || v.GreaterThan(bestVersion) {
+			best = node
+			bestVersion = v
+		}
+	}
+
+	return best
+}
+
+func (g *Graph) Sorted() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Name != nodes[j].Name {
+			return nodes[i].Name < nodes[j].Name
+		}
+		return versionLess(nodes[i].Version, nodes[j].Version)
+	})
+
+	return nodes
+}
+
+// versionLess compares versions by semver precedence, falling back to string
+// order for versions that do not parse.
+func versionLess(a, b string) bool {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	if va.Equal(vb) {
+		return a < b
+	}
+
+	return va.LessThan(vb)
+}
+
+func (g *Graph) Conflicts() []Conflict {
+	byName := make(map[string][]string)
+	for _, node := range g.Sorted() {
+		byName[node.Name] = append(byName[node.Name], node.Version)
+	}
+
+	var conflicts []Conflict
+	for name, versions := range byName {
+		if len(versions) > 1 {
+			conflicts = append(conflicts, Conflict{Name: name, Versions: versions})
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Name < conflicts[j].Name
+	})
+
+	return conflicts
+}
+
+func (g *Graph) Without(names ...string) *Graph {
+	graph := &Graph{
+		Dependencies: make(map[string]string),
+		Edges:        make(map[string]*Node),
+		Nodes:        g.Nodes,
+	}
+
+	for name, spec := range g.Dependencies {
+		graph.Dependencies[name] = spec
+	}
+	for name, node := range g.Edges {
+		graph.Edges[name] = node
+	}
+
+	for _, name := range names {
+		delete(graph.Dependencies, name)
+		delete(graph.Edges, name)
+	}
+
+	return graph.Prune()
+}
+
+func (g *Graph) Prune() *Graph {
+	graph := &Graph{
+		Dependencies: g.Dependencies,
+		Edges:        g.Edges,
+		Nodes:        make(map[string]*Node),
+	}
+
+	var visit func(node *Node)
+	visit = func(node *Node) {
+		key := Key(node.Name, node.Version)
+		if _, ok := graph.Nodes[key]; ok {
+			return
+		}
+
+		graph.Nodes[key] = node
+		for _, dep := range node.Edges {
+			visit(dep)
+		}
+	}
+
+	for _, node := range g.Edges {
+		visit(node)
+	}
+
+	return graph
+}
+
+func (g *Graph) Dependents(node *Node) []*Node {
+	var dependents []*Node
+	for _, candidate := range g.Sorted() {
+		if candidate.Edges[node.Name] == node {
+			dependents = append(dependents, candidate)
+		}
+	}
+
+	return dependents
+}
+
+// supported reports whether a package's os and cpu fields allow the current
+// platform. Entries starting with ! exclude a platform.
+func supported(manifest request.Response) bool {
+	return platformAllowed(manifest.OS, nodePlatform()) && platformAllowed(manifest.CPU, nodeArch())
+}
+
+func platformAllowed(list []string, current string) bool {
+	allowed := true
+	for _, entry := range list {
+		if entry == "!"+current {
+			return false
+		}
+		if !strings.HasPrefix(entry, "!") {
+			allowed = false
+		}
+	}
+	if allowed {
+		return true
+	}
+
+	for _, entry := range list {
+		if entry == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodePlatform and nodeArch return the names Node uses for process.platform
+// and process.arch, which is what os and cpu fields list.
+func nodePlatform() string {
+	if runtime.GOOS == "windows" {
+		return "win32"
+	}
+
+	return runtime.GOOS
+}
+
+func nodeArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "386":
+		return "ia32"
+	case "ppc64le":
+		return "ppc64"
+	case "mipsle":
+		return "mipsel"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+func distIntegrity(manifest request.Response) (string, error) {
+	if manifest.Dist.Integrity == "" && manifest.Dist.Shasum != "" {
+		return integrity.FromShasum(manifest.Dist.Shasum)
+	}
+
+	return manifest.Dist.Integrity, nil
+}
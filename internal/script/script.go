@@ -0,0 +1,141 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/LOTaher/grog/internal/manifest"
+)
+
+var unsafeEnvChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Command builds the shell command for a package.json script, or returns nil
+// if the package does not define it. Extra arguments are appended to the
+// script the way npm does.
+func Command(dir string, pkg *manifest.Manifest, event string, args []string) (*exec.Cmd, error) {
+	scripts, err := pkg.Scripts()
+	if err != nil {
+		return nil, err
+	}
+
+	script, ok := scripts[event]
+	if !ok {
+		return nil, nil
+	}
+
+	for _, arg := range args {
+		script += " " + quote(arg)
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/d", "/s", "/c", script)
+	} else {
+		cmd = exec.Command("sh", "-c", script)
+	}
+
+	env, err := Env(dir, pkg, event, script)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.Dir = dir
+	cmd.Env = env
+	return cmd, nil
+}
+
+func Env(dir string, pkg *manifest.Manifest, event, script string) ([]string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(pkg.Bytes(), &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifest.FileName, err)
+	}
+
+	vars := make(map[string]string)
+	flatten("npm_package", fields, vars)
+	vars["npm_package_json"] = manifest.Path(absDir)
+	vars["npm_lifecycle_event"] = event
+	vars["npm_lifecycle_script"] = script
+	vars["npm_config_user_agent"] = "grog"
+
+	if executable, err := os.Executable(); err == nil {
+		vars["npm_execpath"] = executable
+	}
+
+	var env []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if _, ok := vars[name]; ok || strings.HasPrefix(name, "npm_package_") || strings.EqualFold(name, "PATH") {
+			continue
+		}
+		env = append(env, entry)
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+
+	return append(env, "PATH="+Path(absDir)), nil
+}
+
+// Path prepends the node_modules/.bin directory of dir and of each of its
+// parents to PATH.
+func Path(dir string) string {
+	var paths []string
+	for current := dir; ; current = filepath.Dir(current) {
+		paths = append(paths, filepath.Join(current, "node_modules", ".bin"))
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	if path := os.Getenv("PATH"); path != "" {
+		paths = append(paths, path)
+	}
+
+	return strings.Join(paths, string(os.PathListSeparator))
+}
+
+func flatten(prefix string, value interface{}, vars map[string]string) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			flatten(prefix+"_"+unsafeEnvChars.ReplaceAllString(key, "_"), child, vars)
+		}
+	case []interface{}:
+		for i, child := range value {
+			flatten(fmt.Sprintf("%s_%d", prefix, i), child, vars)
+		}
+	case string:
+		vars[prefix] = value
+	case nil:
+		vars[prefix] = ""
+	default:
+		vars[prefix] = fmt.Sprint(value)
+	}
+}
+
+func quote(arg string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,287 @@
+package tarball
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+	mode     int64
+	body     string
+}
+
+func archive(t *testing.T, entries ...entry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     e.mode,
+			Size:     int64(len(e.body)),
+		}
+		if header.Mode == 0 {
+			header.Mode = 0644
+		}
+		if e.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+// sandbox returns an empty target directory and a sibling directory holding
+// one file that hostile archives try to reach.
+func sandbox(t *testing.T) (string, string) {
+	t.Helper()
+
+	base := t.TempDir()
+	target := filepath.Join(base, "target")
+	outside := filepath.Join(base, "outside")
+
+	for _, dir := range []string{target, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return target, outside
+}
+
+func assertUntouched(t *testing.T, outside string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "secret" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("extraction wrote outside the target: %v", names)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outside, "secret"))
+	if err != nil || string(data) != "secret" {
+		t.Errorf("extraction modified a file outside the target: %q, %v", data, err)
+	}
+}
+
+func TestExtractRejectsHostileArchives(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{
+			name: "parent directory path",
+			entries: []entry{
+				{name: "package/../../outside/pwned", typeflag: tar.TypeReg, body: "x"},
+			},
+		},
+		{
+			name: "parent directory at the start",
+			entries: []entry{
+				{name: "../outside/pwned", typeflag: tar.TypeReg, body: "x"},
+			},
+		},
+		{
+			name: "absolute path",
+			entries: []entry{
+				{name: "/tmp/pwned", typeflag: tar.TypeReg, body: "x"},
+			},
+		},
+		{
+			name: "symlink escaping the target, then a write through it",
+			entries: []entry{
+				{name: "package/escape", typeflag: tar.TypeSymlink, linkname: "../../outside"},
+				{name: "package/escape/pwned", typeflag: tar.TypeReg, body: "x"},
+			},
+		},
+		{
+			name: "symlink to an absolute path",
+			entries: []entry{
+				{name: "package/escape", typeflag: tar.TypeSymlink, linkname: "/"},
+			},
+		},
+		{
+			name: "symlink escaping through another symlink",
+			entries: []entry{
+				{name: "package/up", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "package/escape", typeflag: tar.TypeSymlink, linkname: "up/../outside"},
+			},
+		},
+		{
+			name: "write through a symlink inside the target",
+			entries: []entry{
+				{name: "package/real/", typeflag: tar.TypeDir},
+				{name: "package/link", typeflag: tar.TypeSymlink, linkname: "real"},
+				{name: "package/link/file", typeflag: tar.TypeReg, body: "x"},
+			},
+		},
+		{
+			name: "overwrite a symlink with a file",
+			entries: []entry{
+				{name: "package/secret", typeflag: tar.TypeSymlink, linkname: "../../outside/secret"},
+				{name: "package/secret", typeflag: tar.TypeReg, body: "pwned"},
+			},
+		},
+		{
+			name: "hardlink outside the target",
+			entries: []entry{
+				{name: "package/secret", typeflag: tar.TypeLink, linkname: "../outside/secret"},
+			},
+		},
+		{
+			name: "hardlink to an absolute path",
+			entries: []entry{
+				{name: "package/passwd", typeflag: tar.TypeLink, linkname: "/etc/passwd"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target, outside := sandbox(t)
+
+			if err := extract(archive(t, tc.entries...), target); err == nil {
+				t.Error("expected extraction to fail")
+			}
+
+			assertUntouched(t, outside)
+		})
+	}
+}
+
+func TestExtractStripsSpecialModes(t *testing.T) {
+	target, _ := sandbox(t)
+
+	buf := archive(t,
+		entry{name: "package/setuid", typeflag: tar.TypeReg, mode: 04755, body: "x"},
+		entry{name: "package/setgid", typeflag: tar.TypeReg, mode: 02644, body: "x"},
+		entry{name: "package/writable", typeflag: tar.TypeReg, mode: 0666, body: "x"},
+	)
+	if err := extract(buf, target); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]os.FileMode{"setuid": 0755, "setgid": 0644, "writable": 0644}
+	for name, mode := range want {
+		info, err := os.Stat(filepath.Join(target, "package", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != 0 {
+			t.Errorf("%s kept special mode bits: %v", name, info.Mode())
+		}
+		if info.Mode().Perm()&^mode != 0 {
+			t.Errorf("%s has mode %v, want at most %v", name, info.Mode().Perm(), mode)
+		}
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	maxEntries, maxFileSize, maxTotalSize := MaxEntries, MaxFileSize, MaxTotalSize
+	defer func() {
+		MaxEntries, MaxFileSize, MaxTotalSize = maxEntries, maxFileSize, maxTotalSize
+	}()
+	MaxEntries, MaxFileSize, MaxTotalSize = 3, 8, 12
+
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{
+			name: "oversized entry",
+			entries: []entry{
+				{name: "package/big", typeflag: tar.TypeReg, body: "0123456789"},
+			},
+		},
+		{
+			name: "oversized archive",
+			entries: []entry{
+				{name: "package/one", typeflag: tar.TypeReg, body: "01234567"},
+				{name: "package/two", typeflag: tar.TypeReg, body: "01234567"},
+			},
+		},
+		{
+			name: "too many entries",
+			entries: []entry{
+				{name: "package/1", typeflag: tar.TypeReg, body: "1"},
+				{name: "package/2", typeflag: tar.TypeReg, body: "2"},
+				{name: "package/3", typeflag: tar.TypeReg, body: "3"},
+				{name: "package/4", typeflag: tar.TypeReg, body: "4"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target, outside := sandbox(t)
+
+			if err := extract(archive(t, tc.entries...), target); err == nil {
+				t.Error("expected extraction to fail")
+			}
+
+			assertUntouched(t, outside)
+		})
+	}
+}
+
+func TestExtractAllowsSafeLinks(t *testing.T) {
+	target, outside := sandbox(t)
+
+	buf := archive(t,
+		entry{name: "package/lib/index.js", typeflag: tar.TypeReg, body: "module.exports = 1"},
+		entry{name: "package/main.js", typeflag: tar.TypeSymlink, linkname: "lib/index.js"},
+		entry{name: "package/copy.js", typeflag: tar.TypeLink, linkname: "package/lib/index.js"},
+	)
+	if err := extract(buf, target); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"main.js", "copy.js"} {
+		data, err := os.ReadFile(filepath.Join(target, "package", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "module.exports = 1" {
+			t.Errorf("%s has %q", name, data)
+		}
+	}
+
+	assertUntouched(t, outside)
+}
@@ -0,0 +1,73 @@
+package tarball
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+var algorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha512", sha512.New},
+	{"sha384", sha512.New384},
+	{"sha256", sha256.New},
+	{"sha1", sha1.New},
+}
+
+type verifier struct {
+	algorithm string
+	expected  []string
+	hash      hash.Hash
+}
+
+func newVerifier(integrity string) (*verifier, error) {
+	entries := strings.Fields(integrity)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no integrity information")
+	}
+
+	for _, algorithm := range algorithms {
+		var expected []string
+		for _, entry := range entries {
+			name, digest, ok := strings.Cut(entry, "-")
+			if !ok || name != algorithm.name {
+				continue
+			}
+			if i := strings.IndexByte(digest, '?'); i >= 0 {
+				digest = digest[:i]
+			}
+			expected = append(expected, digest)
+		}
+
+		if len(expected) > 0 {
+			return &verifier{algorithm: algorithm.name, expected: expected, hash: algorithm.new()}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported integrity '%s'", integrity)
+}
+
+func (v *verifier) Write(p []byte) (int, error) {
+	return v.hash.Write(p)
+}
+
+func (v *verifier) sum() string {
+	return v.algorithm + "-" + base64.StdEncoding.EncodeToString(v.hash.Sum(nil))
+}
+
+func (v *verifier) verify() error {
+	actual := v.sum()
+	for _, expected := range v.expected {
+		if v.algorithm+"-"+expected == actual {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("integrity mismatch: expected %s-%s, got %s", v.algorithm, v.expected[0], actual)
+}
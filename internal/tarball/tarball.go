@@ -8,20 +8,56 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-func DownloadTarball(url, targetDir string) error {
+var (
+	MaxEntries         = 100000
+	MaxFileSize  int64 = 512 << 20
+	MaxTotalSize int64 = 2 << 30
+)
+
+func DownloadTarball(url, targetDir, integrity string) error {
+	verifier, err := newVerifier(integrity)
+	if err != nil {
+		return fmt.Errorf("refusing to download %s: %w", url, err)
+	}
+
 	resp, err := http.Get(url)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s downloading %s", resp.Status, url)
+	}
+
 	if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
 		return err
 	}
 
-	gzipReader, err := gzip.NewReader(resp.Body)
+	body := io.TeeReader(resp.Body, verifier)
+	if err := extract(body, targetDir); err != nil {
+		os.RemoveAll(targetDir)
+		return err
+	}
+
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		os.RemoveAll(targetDir)
+		return err
+	}
+
+	if err := verifier.verify(); err != nil {
+		os.RemoveAll(targetDir)
+		return fmt.Errorf("integrity check failed for %s: %w", url, err)
+	}
+
+	return nil
+}
+
+func extract(r io.Reader, targetDir string) error {
+	gzipReader, err := gzip.NewReader(r)
 	if err != nil {
 		return err
 	}
@@ -29,6 +65,9 @@ func DownloadTarball(url, targetDir string) error {
 
 	tarReader := tar.NewReader(gzipReader)
 
+	entries := 0
+	var total int64
+
 	for {
 		header, err := tarReader.Next()
 
@@ -39,7 +78,19 @@ func DownloadTarball(url, targetDir string) error {
 			return err
 		}
 
-		outputPath := filepath.Join(targetDir, header.Name)
+		entries++
+		if entries > MaxEntries {
+			return fmt.Errorf("archive has more than %d entries", MaxEntries)
+		}
+
+		outputPath, err := safeJoin(targetDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := checkParents(targetDir, outputPath); err != nil {
+			return err
+		}
 
 		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 			return fmt.Errorf("failed to create directory for %s: %w", outputPath, err)
@@ -53,19 +104,170 @@ func DownloadTarball(url, targetDir string) error {
 			}
 
 		case tar.TypeReg:
-			outFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
+			if header.Size > MaxFileSize {
+				return fmt.Errorf("entry %s is larger than %d bytes", header.Name, MaxFileSize)
+			}
+
+			total += header.Size
+			if total > MaxTotalSize {
+				return fmt.Errorf("archive expands to more than %d bytes", MaxTotalSize)
+			}
+
+			if err := removeExisting(outputPath); err != nil {
+				return err
+			}
+
+			outFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, sanitizeMode(header.Mode))
 			if err != nil {
 				return err
 			}
 
-			if _, err := io.Copy(outFile, tarReader); err != nil {
+			if _, err := io.CopyN(outFile, tarReader, header.Size); err != nil {
 				outFile.Close()
 				return err
 			}
 
-			outFile.Close()
+			if err := outFile.Close(); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			if filepath.IsAbs(header.Linkname) {
+				return fmt.Errorf("symlink %s points to absolute path %s", header.Name, header.Linkname)
+			}
+
+			if err := checkSymlink(targetDir, filepath.Dir(outputPath), header.Linkname); err != nil {
+				return fmt.Errorf("symlink %s escapes the package: %w", header.Name, err)
+			}
+
+			if err := removeExisting(outputPath); err != nil {
+				return err
+			}
+
+			if err := os.Symlink(header.Linkname, outputPath); err != nil {
+				return err
+			}
+
+		case tar.TypeLink:
+			linkPath, err := safeJoin(targetDir, header.Linkname)
+			if err == nil {
+				err = checkParents(targetDir, linkPath)
+			}
+			if err != nil {
+				return fmt.Errorf("hardlink %s escapes the package: %w", header.Name, err)
+			}
+
+			info, err := os.Lstat(linkPath)
+			if err != nil {
+				return fmt.Errorf("hardlink %s points to missing entry %s", header.Name, header.Linkname)
+			}
+			if !info.Mode().IsRegular() {
+				return fmt.Errorf("hardlink %s must point to a regular file", header.Name)
+			}
+
+			if err := removeExisting(outputPath); err != nil {
+				return err
+			}
+
+			if err := os.Link(linkPath, outputPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func safeJoin(targetDir, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return "", fmt.Errorf("entry %s has an absolute path", name)
+	}
+
+	return within(targetDir, filepath.Join(targetDir, name))
+}
+
+func within(targetDir, path string) (string, error) {
+	rel, err := filepath.Rel(targetDir, path)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside %s", path, targetDir)
+	}
+
+	return path, nil
+}
+
+func checkParents(targetDir, path string) error {
+	rel, err := filepath.Rel(targetDir, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return err
+	}
+
+	current := targetDir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("cannot extract %s through symlink %s", path, current)
+		}
+	}
+
+	return nil
+}
+
+func checkSymlink(targetDir, dir, linkname string) error {
+	root, err := filepath.EvalSymlinks(targetDir)
+	if err != nil {
+		return err
+	}
+
+	current, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+		default:
+			current = filepath.Join(current, part)
+			if resolved, err := filepath.EvalSymlinks(current); err == nil {
+				current = resolved
+			}
+		}
+
+		if _, err := within(root, current); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+func removeExisting(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func sanitizeMode(mode int64) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+
+	return 0644
+}
@@ -0,0 +1,372 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+type comparator struct {
+	operator string
+	version  *semver.Version
+}
+
+type Range struct {
+	raw  string
+	sets [][]comparator
+}
+
+type partial struct {
+	major, minor, patch string
+	prerelease          string
+}
+
+var (
+	orSeparator    = regexp.MustCompile(`\s*\|\|\s*`)
+	hyphenRange    = regexp.MustCompile(`^\s*(\S+)\s+-\s+(\S+)\s*$`)
+	operatorSpaces = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+	comparatorExpr = regexp.MustCompile(`^(<=|>=|<|>|=|~>|~|\^)?(.*)$`)
+	partialExpr    = regexp.MustCompile(`^[v=\s]*(\d+|[xX*])(?:\.(\d+|[xX*])(?:\.(\d+|[xX*])(?:-?([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?)?)?$`)
+)
+
+func ParseRange(constraintStr string) (*Range, error) {
+	r := &Range{raw: constraintStr}
+
+	for _, set := range orSeparator.Split(strings.TrimSpace(constraintStr), -1) {
+		comparators, err := parseSet(set)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range '%s': %w", constraintStr, err)
+		}
+		r.sets = append(r.sets, comparators)
+	}
+
+	return r, nil
+}
+
+func ValidRange(constraintStr string) bool {
+	_, err := ParseRange(constraintStr)
+	return err == nil
+}
+
+func (r *Range) String() string {
+	return r.raw
+}
+
+func (r *Range) Test(v *semver.Version) bool {
+	for _, set := range r.sets {
+		if testSet(set, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testSet(set []comparator, v *semver.Version) bool {
+	for _, c := range set {
+		if !c.test(v) {
+			return false
+		}
+	}
+
+	if v.Prerelease() == "" {
+		return true
+	}
+
+	for _, c := range set {
+		if c.version == nil || c.version.Prerelease() == "" {
+			continue
+		}
+
+		if c.version.Major() == v.Major() && c.version.Minor() == v.Minor() && c.version.Patch() == v.Patch() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c comparator) test(v *semver.Version) bool {
+	if c.version == nil {
+		return true
+	}
+
+	cmp := v.Compare(c.version)
+	switch c.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+func parseSet(set string) ([]comparator, error) {
+	if match := hyphenRange.FindStringSubmatch(set); match != nil {
+		return parseHyphen(match[1], match[2])
+	}
+
+	set = operatorSpaces.ReplaceAllString(strings.TrimSpace(set), "$1")
+	if set == "" {
+		return []comparator{{}}, nil
+	}
+
+	var comparators []comparator
+	for _, token := range strings.Fields(set) {
+		parsed, err := parseComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, parsed...)
+	}
+
+	return comparators, nil
+}
+
+func parseHyphen(fromStr, toStr string) ([]comparator, error) {
+	from, err := parsePartial(fromStr)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parsePartial(toStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var comparators []comparator
+
+	if !isX(from.major) {
+		lower, err := from.floor()
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, comparator{operator: ">=", version: lower})
+	}
+
+	switch {
+	case isX(to.major):
+	case isX(to.minor):
+		upper, err := to.bump(0)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, comparator{operator: "<", version: upper})
+	case isX(to.patch):
+		upper, err := to.bump(1)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, comparator{operator: "<", version: upper})
+	default:
+		upper, err := to.floor()
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, comparator{operator: "<=", version: upper})
+	}
+
+	if len(comparators) == 0 {
+		comparators = append(comparators, comparator{})
+	}
+
+	return comparators, nil
+}
+
+func parseComparator(token string) ([]comparator, error) {
+	match := comparatorExpr.FindStringSubmatch(token)
+	operator, rest := match[1], match[2]
+
+	p, err := parsePartial(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operator {
+	case "^":
+		return p.caret()
+	case "~", "~>":
+		return p.tilde()
+	default:
+		return p.xRange(operator)
+	}
+}
+
+func parsePartial(s string) (partial, error) {
+	match := partialExpr.FindStringSubmatch(s)
+	if match == nil {
+		return partial{}, fmt.Errorf("invalid version '%s'", s)
+	}
+
+	p := partial{major: match[1], minor: match[2], patch: match[3], prerelease: match[4]}
+	if p.minor == "" {
+		p.minor = "x"
+	}
+	if p.patch == "" {
+		p.patch = "x"
+	}
+	if isX(p.major) {
+		p.minor, p.patch = "x", "x"
+	} else if isX(p.minor) {
+		p.patch = "x"
+	}
+
+	return p, nil
+}
+
+func isX(part string) bool {
+	return part == "x" || part == "X" || part == "*"
+}
+
+func (p partial) numbers() (uint64, uint64, uint64) {
+	major, _ := strconv.ParseUint(p.major, 10, 64)
+	minor, _ := strconv.ParseUint(p.minor, 10, 64)
+	patch, _ := strconv.ParseUint(p.patch, 10, 64)
+	return major, minor, patch
+}
+
+func (p partial) floor() (*semver.Version, error) {
+	major, minor, patch := p.numbers()
+	if p.prerelease != "" && !isX(p.patch) {
+		return semver.NewVersion(fmt.Sprintf("%d.%d.%d-%s", major, minor, patch, p.prerelease))
+	}
+	return semver.NewVersion(fmt.Sprintf("%d.%d.%d", major, minor, patch))
+}
+
+func (p partial) bump(position int) (*semver.Version, error) {
+	major, minor, patch := p.numbers()
+	switch position {
+	case 0:
+		return semver.NewVersion(fmt.Sprintf("%d.0.0-0", major+1))
+	case 1:
+		return semver.NewVersion(fmt.Sprintf("%d.%d.0-0", major, minor+1))
+	default:
+		return semver.NewVersion(fmt.Sprintf("%d.%d.%d-0", major, minor, patch+1))
+	}
+}
+
+func (p partial) bounded(upperPosition int) ([]comparator, error) {
+	lower, err := p.floor()
+	if err != nil {
+		return nil, err
+	}
+
+	upper, err := p.bump(upperPosition)
+	if err != nil {
+		return nil, err
+	}
+
+	return []comparator{{operator: ">=", version: lower}, {operator: "<", version: upper}}, nil
+}
+
+func (p partial) caret() ([]comparator, error) {
+	major, minor, _ := p.numbers()
+
+	switch {
+	case isX(p.major):
+		return []comparator{{}}, nil
+	case isX(p.minor):
+		return p.bounded(0)
+	case isX(p.patch):
+		if major == 0 {
+			return p.bounded(1)
+		}
+		return p.bounded(0)
+	case major != 0:
+		return p.bounded(0)
+	case minor != 0:
+		return p.bounded(1)
+	default:
+		return p.bounded(2)
+	}
+}
+
+func (p partial) tilde() ([]comparator, error) {
+	switch {
+	case isX(p.major):
+		return []comparator{{}}, nil
+	case isX(p.minor):
+		return p.bounded(0)
+	default:
+		return p.bounded(1)
+	}
+}
+
+func (p partial) xRange(operator string) ([]comparator, error) {
+	if operator == "=" {
+		operator = ""
+	}
+
+	if isX(p.major) {
+		if operator == "<" || operator == ">" {
+			none, err := semver.NewVersion("0.0.0-0")
+			if err != nil {
+				return nil, err
+			}
+			return []comparator{{operator: "<", version: none}}, nil
+		}
+		return []comparator{{}}, nil
+	}
+
+	if !isX(p.patch) {
+		v, err := p.floor()
+		if err != nil {
+			return nil, err
+		}
+		return []comparator{{operator: operator, version: v}}, nil
+	}
+
+	position := 1
+	if isX(p.minor) {
+		position = 0
+	}
+
+	switch operator {
+	case "":
+		return p.bounded(position)
+	case ">":
+		v, err := p.bump(position)
+		if err != nil {
+			return nil, err
+		}
+		return []comparator{{operator: ">=", version: trimPrerelease(v)}}, nil
+	case ">=":
+		v, err := p.floor()
+		if err != nil {
+			return nil, err
+		}
+		return []comparator{{operator: ">=", version: v}}, nil
+	case "<":
+		v, err := p.floor()
+		if err != nil {
+			return nil, err
+		}
+		floor, err := semver.NewVersion(v.String() + "-0")
+		if err != nil {
+			return nil, err
+		}
+		return []comparator{{operator: "<", version: floor}}, nil
+	default:
+		v, err := p.bump(position)
+		if err != nil {
+			return nil, err
+		}
+		return []comparator{{operator: "<", version: v}}, nil
+	}
+}
+
+func trimPrerelease(v *semver.Version) *semver.Version {
+	trimmed, err := v.SetPrerelease("")
+	if err != nil {
+		return v
+	}
+	return &trimmed
+}
@@ -5,20 +5,34 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"sort"
+	"strings"
     "os"
     "path/filepath"
 
+	"github.com/LOTaher/grog/internal/request"
 	"github.com/Masterminds/semver/v3"
 )
 
 type Version struct {
-	Versions map[string]interface{} `json:"versions"`
+	Name     string                      `json:"name"`
+	DistTags map[string]string           `json:"dist-tags"`
+	Versions map[string]request.Response `json:"versions"`
 }
 
+var tagName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
 func (v *Version) reqRegistry(packageName string) error {
 	url := fmt.Sprintf("https://registry.npmjs.org/%s", packageName)
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8, */*")
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -38,15 +52,68 @@ func (v *Version) reqRegistry(packageName string) error {
 	return nil
 }
 
+func (v *Version) Available() []string {
+	available := make([]string, 0, len(v.Versions))
+	for version := range v.Versions {
+		available = append(available, version)
+	}
+
+	return available
+}
+
+func (v *Version) Latest() string {
+	if latest, ok := v.DistTags["latest"]; ok {
+		return latest
+	}
+
+	return HighestVersion(v.Available())
+}
+
+func (v *Version) Resolve(spec string) (string, error) {
+	constraint, err := ParseRange(spec)
+	if err != nil {
+		tagged, ok := v.DistTags[spec]
+		if !ok {
+			return "", fmt.Errorf("'%s' is neither a valid range nor a dist-tag of %s", spec, v.Name)
+		}
+		return tagged, nil
+	}
+
+	if latest := v.Latest(); latest != "" {
+		if parsed, err := semver.StrictNewVersion(latest); err == nil && constraint.Test(parsed) {
+			return latest, nil
+		}
+	}
+
+	return SelectVersion(v.Available(), spec)
+}
+
+func FetchVersions(packageName string) (Version, error) {
+	versions := Version{}
+	if err := versions.reqRegistry(packageName); err != nil {
+		return Version{}, err
+	}
+
+	if len(versions.Versions) == 0 {
+		return Version{}, fmt.Errorf("package %s not found in registry", packageName)
+	}
+
+	return versions, nil
+}
+
 func BestMatchingVersion(packageName, constraintStr string) (string, error) {
 	versions := Version{}
 	if err := versions.reqRegistry(packageName); err != nil {
 		return "", err
 	}
 
+	return versions.Resolve(constraintStr)
+}
+
+func SelectVersion(available []string, constraintStr string) (string, error) {
 	var parsedVersions []*semver.Version
-	for vStr := range versions.Versions {
-		v, err := semver.NewVersion(vStr)
+	for _, vStr := range available {
+		v, err := semver.StrictNewVersion(vStr)
 		if err != nil {
 			continue
 		}
@@ -54,16 +121,16 @@ func BestMatchingVersion(packageName, constraintStr string) (string, error) {
 	}
 
 	sort.Slice(parsedVersions, func(i, j int) bool {
-		return parsedVersions[i].LessThan(parsedVersions[j])
+		return parsedVersions[i].GreaterThan(parsedVersions[j])
 	})
 
-	constraint, err := semver.NewConstraint(constraintStr)
+	constraint, err := ParseRange(constraintStr)
 	if err != nil {
 		return "", err
 	}
 
 	for _, v := range parsedVersions {
-		if constraint.Check(v) {
+		if constraint.Test(v) {
 			return v.String(), nil
 		}
 	}
@@ -71,8 +138,26 @@ func BestMatchingVersion(packageName, constraintStr string) (string, error) {
 	return "", fmt.Errorf("no version found that satisfies the constraint '%s'", constraintStr)
 }
 
+func Satisfies(version, constraintStr string) (bool, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	constraint, err := ParseRange(constraintStr)
+	if err != nil {
+		return false, err
+	}
+
+	return constraint.Test(v), nil
+}
+
+func ValidSpec(spec string) bool {
+	return ValidRange(spec) || tagName.MatchString(spec)
+}
+
 func ValidVersion(version string) (bool, error) {
-	_, err := semver.NewVersion(version)
+	_, err := semver.StrictNewVersion(strings.TrimLeft(version, "v="))
 	if err != nil {
 		return false, fmt.Errorf("invalid version '%s' with error: %s", version, err)
 	}
@@ -87,8 +172,12 @@ func GetMostRecentVersion(pkg string) string {
 		return ""
 	}
 
+	return versions.Latest()
+}
+
+func HighestVersion(available []string) string {
 	var highestVersion *semver.Version
-	for version := range versions.Versions {
+	for _, version := range available {
 		parsedVersion, err := semver.NewVersion(version)
 		if err != nil {
 			fmt.Printf("Error parsing version '%s': %v\n", version, err)
@@ -108,12 +197,12 @@ func GetMostRecentVersion(pkg string) string {
 }
 
 func IsLatestVersion(pkg, version string) (bool, error) {
-    mostRecentVersion := GetMostRecentVersion(pkg)
-    if mostRecentVersion == "" {
-        return false, fmt.Errorf("failed to get most recent version for %s", pkg)
+    latestVersion := GetMostRecentVersion(pkg)
+    if latestVersion == "" {
+        return false, fmt.Errorf("failed to get latest version for %s", pkg)
     }
 
-    return version == mostRecentVersion, nil
+    return version == latestVersion, nil
 }
 
 func GetVersions(name string) ([]string, error) {
@@ -130,7 +219,7 @@ func GetVersions(name string) ([]string, error) {
 
 	var versionStrings []string
 	for _, version := range versions {
-		if version.IsDir() {
+		if version.IsDir() && !strings.HasPrefix(version.Name(), ".") {
 			versionStrings = append(versionStrings, version.Name())
 		}
 	}
@@ -152,7 +241,7 @@ func GetLatestVersion(name string) (string, error) {
 
 	var latestVersion string
 	for _, version := range versions {
-		if version.IsDir() {
+		if version.IsDir() && !strings.HasPrefix(version.Name(), ".") {
 			latestVersion = version.Name()
 		}
 	}
@@ -166,31 +255,10 @@ func FindCorrectVersion(name, versionConstraint string) (string, error) {
 		return "", fmt.Errorf("failed to get versions: %w", err)
 	}
 
-	constraint, err := semver.NewConstraint(versionConstraint)
+	version, err := SelectVersion(versions, versionConstraint)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse version constraint '%s': %w", versionConstraint, err)
-	}
-
-	var satisfyingVersions []*semver.Version
-	for _, v := range versions {
-		version, err := semver.NewVersion(v)
-		if err != nil {
-            return "", fmt.Errorf("failed to parse version '%s': %w", v, err)
-		}
-
-		if constraint.Check(version) {
-			satisfyingVersions = append(satisfyingVersions, version)
-		}
-	}
-
-	if len(satisfyingVersions) == 0 {
-		return "", fmt.Errorf("no version found that satisfies the constraint '%s' for package %s", versionConstraint, name)
+		return "", fmt.Errorf("failed to find a cached version of %s: %w", name, err)
 	}
 
-	sort.Slice(satisfyingVersions, func(i, j int) bool {
-		return satisfyingVersions[i].LessThan(satisfyingVersions[j])
-	})
-
-	return satisfyingVersions[len(satisfyingVersions)-1].String(), nil
+	return version, nil
 }
-